@@ -1,6 +1,7 @@
 package account
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -46,6 +47,11 @@ func NewAccountClient(accessToken string) *Account {
 
 // 获取网盘用户信息
 func (a *Account) UserInfo() (UserInfoResponse, error) {
+	return a.UserInfoWithContext(context.Background())
+}
+
+// 获取网盘用户信息，ctx取消时中断请求
+func (a *Account) UserInfoWithContext(ctx context.Context) (UserInfoResponse, error) {
 	ret := UserInfoResponse{}
 
 	v := url.Values{}
@@ -53,7 +59,7 @@ func (a *Account) UserInfo() (UserInfoResponse, error) {
 	query := v.Encode()
 
 	requestUrl := conf.OpenApiDomain + UserInfoUri + "&" + query
-	resp, err := httpclient.Get(requestUrl, map[string]string{})
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
 	if err != nil {
 		log.Println("httpclient.Get failed, err:", err)
 		return ret, err
@@ -83,6 +89,11 @@ func (a *Account) UserInfo() (UserInfoResponse, error) {
 
 // 获取用户网盘容量信息
 func (a *Account) Quota() (QuotaResponse, error) {
+	return a.QuotaWithContext(context.Background())
+}
+
+// 获取用户网盘容量信息，ctx取消时中断请求
+func (a *Account) QuotaWithContext(ctx context.Context) (QuotaResponse, error) {
 	ret := QuotaResponse{}
 
 	v := url.Values{}
@@ -92,7 +103,7 @@ func (a *Account) Quota() (QuotaResponse, error) {
 	query := v.Encode()
 
 	requestUrl := conf.OpenApiDomain + QuotaUri + "?" + query
-	resp, err := httpclient.Get(requestUrl, map[string]string{})
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
 	if err != nil {
 		log.Println("httpclient.Get failed, err:", err)
 		return ret, err
@@ -2,6 +2,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -86,6 +87,11 @@ func (a *Auth) OAuthUrl(redirectUri string) string {
 
 // 获取AccessToken
 func (a *Auth) AccessToken(code, redirectUri string) (AccessTokenResponse, error) {
+	return a.AccessTokenWithContext(context.Background(), code, redirectUri)
+}
+
+// 获取AccessToken，ctx取消时中断请求
+func (a *Auth) AccessTokenWithContext(ctx context.Context, code, redirectUri string) (AccessTokenResponse, error) {
 	ret := AccessTokenResponse{}
 
 	v := url.Values{}
@@ -98,7 +104,7 @@ func (a *Auth) AccessToken(code, redirectUri string) (AccessTokenResponse, error
 
 	requestUrl := conf.BaiduOpenApiDomain + OAuthTokenUri + "?" + query
 
-	resp, err := httpclient.Get(requestUrl, map[string]string{})
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
 	if err != nil {
 		log.Println("httpclient.Get failed, err:", err)
 		return ret, err
@@ -121,6 +127,11 @@ func (a *Auth) AccessToken(code, redirectUri string) (AccessTokenResponse, error
 
 // 刷新AccessToken
 func (a *Auth) RefreshToken(refreshToken string) (RefreshTokenResponse, error) {
+	return a.RefreshTokenWithContext(context.Background(), refreshToken)
+}
+
+// 刷新AccessToken，ctx取消时中断请求
+func (a *Auth) RefreshTokenWithContext(ctx context.Context, refreshToken string) (RefreshTokenResponse, error) {
 	ret := RefreshTokenResponse{}
 
 	v := url.Values{}
@@ -132,7 +143,7 @@ func (a *Auth) RefreshToken(refreshToken string) (RefreshTokenResponse, error) {
 
 	requestUrl := conf.BaiduOpenApiDomain + OAuthTokenUri + "?" + query
 
-	resp, err := httpclient.Get(requestUrl, map[string]string{})
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
 	if err != nil {
 		log.Println("httpclient.Get failed, err:", err)
 		return ret, err
@@ -156,6 +167,12 @@ func (a *Auth) RefreshToken(refreshToken string) (RefreshTokenResponse, error) {
 // 获取授权用户的百度账号信息，可以通过unionid字段来识别多个百度产品授权的是否是同一用户
 // 注：获取网盘账号信息请使用account.UserInfo方法
 func (a *Auth) UserInfo(accessToken string) (UserInfoResponse, error) {
+	return a.UserInfoWithContext(context.Background(), accessToken)
+}
+
+// 获取授权用户的百度账号信息，可以通过unionid字段来识别多个百度产品授权的是否是同一用户，ctx取消时中断请求
+// 注：获取网盘账号信息请使用account.UserInfo方法
+func (a *Auth) UserInfoWithContext(ctx context.Context, accessToken string) (UserInfoResponse, error) {
 	ret := UserInfoResponse{}
 
 	v := url.Values{}
@@ -165,7 +182,7 @@ func (a *Auth) UserInfo(accessToken string) (UserInfoResponse, error) {
 
 	requestUrl := conf.BaiduOpenApiDomain + UserInfoUri + "?" + query
 
-	resp, err := httpclient.Get(requestUrl, map[string]string{})
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
 	if err != nil {
 		log.Println("httpclient.Get failed, err:", err)
 		return ret, err
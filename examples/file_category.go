@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	fileClient := file.NewFileClient(accessToken)
+
+	// 各分类的文件数量和大小
+	info, err := fileClient.CategoryInfo("/apps/书梯")
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	for category, stat := range info.Info {
+		fmt.Println(category, stat.Count, stat.Size)
+	}
+
+	// 递归获取目录下的所有视频
+	it := fileClient.CategoryListIter(file.CategoryVideo, file.CategoryListOptions{Dir: "/apps/书梯", Recursion: true})
+	for it.Next() {
+		fmt.Println(it.Item().Path)
+	}
+	if err := it.Err(); err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+}
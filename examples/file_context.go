@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+	"github.com/jsyzchen/pan/utils/httpclient"
+	"os"
+	"os/signal"
+	"time"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	fileClient := file.NewFileClient(accessToken)
+
+	// 方式1：设置超时时间，超时后中断请求
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := fileClient.ListWithContext(ctx, "/apps/书梯", 0, 100)
+	if err != nil {
+		fmt.Println("1.ListWithContext failed, err:", err)
+		return
+	}
+	fmt.Println(res)
+
+	// 方式2：按Ctrl+C取消上传，正在上传的分片请求会被中断，断点续传时已上传的分片下次不需要重新上传
+	ctx, stop := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		stop()
+	}()
+	store := file.NewFileUploadStateStore("/tmp/pan_upload_state")
+	fileUploader := file.NewResumableUploader(accessToken, "/apps/书梯/CHSS.mkv", "/Download/CHSS.mkv", store)
+	uploadRes, err := fileUploader.UploadWithContext(ctx)
+	if errors.Is(err, context.Canceled) {
+		fmt.Println("2.upload canceled")
+	} else if err != nil {
+		fmt.Println("2.UploadWithContext failed, err:", err)
+	} else {
+		fmt.Println(uploadRes)
+	}
+
+	// 方式3：取消下载，未开启断点续传时下载中的临时文件会被删除
+	fileDownloader := file.NewDownloaderWithFsID(accessToken, 759719327699432, "/Download/CHSS.mkv")
+	if err := fileDownloader.DownloadWithContext(ctx); err != nil {
+		fmt.Println("3.DownloadWithContext failed, err:", err)
+	}
+
+	// 不传ctx的接口默认超时时间为60秒，可以修改默认的http.Client
+	httpclient.DefaultClient.Timeout = 30 * time.Second
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/jsyzchen/pan/conf"
 	"github.com/jsyzchen/pan/file"
+	fileUtil "github.com/jsyzchen/pan/utils/file"
 )
 
 func main() {
@@ -29,7 +31,7 @@ func main() {
 	}
 	fmt.Println("2.fileDownloader.Download success")
 
-	// 方式3：通过文件路径下载，非开放平台公开接口，生产环境谨慎使用
+	// 方式3：通过文件路径下载
 	fileDownloader = file.NewDownloaderWithPath(conf.TestData.AccessToken, conf.TestData.Path, conf.TestData.LocalFilePath)
 	err := fileDownloader.Download()
 	if err != nil {
@@ -37,4 +39,37 @@ func main() {
 		return
 	}
 	fmt.Println("3.fileDownloader.DownloaderWithPath success")
+
+	// 方式4：断点续传，中断后再次执行只下载缺失的部分，下载地址失效时会通过fsID自动刷新
+	fileDownloader = file.NewDownloaderWithFsID(accessToken, fsID, localFilePath)
+	fileDownloader.Resumable = true
+	if err := fileDownloader.Download(); err != nil {
+		fmt.Println("4.fileDownloader.Resumable failed, err:", err)
+		return
+	}
+	fmt.Println("4.fileDownloader.Resumable success")
+
+	// 方式5：下载完成后校验md5，不一致时自动重新下载有误的部分
+	fileDownloader = file.NewDownloaderWithFsID(accessToken, fsID, localFilePath)
+	fileDownloader.Verify = true
+	if err := fileDownloader.Download(); err != nil {
+		fmt.Println("5.fileDownloader.Verify failed, err:", err)
+		return
+	}
+	fmt.Println("5.fileDownloader.Verify success")
+
+	// 方式6：分片失败时从已收到的位置继续重试，仍然失败时可以检查每个分片的错误
+	fileDownloader = file.NewDownloaderWithFsID(accessToken, fsID, localFilePath)
+	fileDownloader.MaxRetries = 5
+	if err := fileDownloader.Download(); err != nil {
+		var multiErr *fileUtil.MultiError
+		if errors.As(err, &multiErr) {
+			for _, partErr := range multiErr.Errors {
+				fmt.Printf("6.part[%d] bytes=%d-%d failed, err:%v\n", partErr.Index, partErr.From, partErr.To, partErr.Err)
+			}
+		}
+		fmt.Println("6.fileDownloader.MaxRetries failed, err:", err)
+		return
+	}
+	fmt.Println("6.fileDownloader.MaxRetries success")
 }
\ No newline at end of file
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	remoteDir := "/apps/书梯/project"
+	localDir := "/Download/project"
+
+	fileClient := file.NewFileClient(accessToken)
+	report, err := fileClient.DownloadDir(remoteDir, localDir, file.DownloadDirOptions{
+		Exclude: []string{".git", "*.tmp"},
+		Concurrency: 4,
+		Resumable: true, // 中断后再次执行时已下载的分片不再下载
+		OnFileDone: func(item file.DownloadDirItem, status int) {
+			fmt.Println(status, item.Path)
+		},
+	})
+	if err != nil {
+		fmt.Println("err:", err)
+	}
+
+	fmt.Printf("downloaded:%d skipped:%d failed:%d\n", len(report.Downloaded), len(report.Skipped), len(report.Failed))
+	for _, item := range report.Failed {
+		fmt.Println("failed:", item.Path, item.Err)
+	}
+}
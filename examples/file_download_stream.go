@@ -0,0 +1,39 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+	"net/http"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	var fsID uint64
+	fsID = 759719327699432
+
+	// 方式1：下载内容直接写入http响应，不保存到本地
+	http.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		fileDownloader := file.NewDownloaderWithFsID(accessToken, fsID, "")
+		if _, err := fileDownloader.DownloadTo(r.Context(), w); err != nil {
+			fmt.Println("1.fileDownloader.DownloadTo failed, err:", err)
+		}
+	})
+
+	// 方式2：随机读取网盘上的zip文件，只下载需要的部分
+	remoteFile, err := file.NewDownloaderWithPath(accessToken, "/apps/书梯/test.zip", "").RemoteFile()
+	if err != nil {
+		fmt.Println("2.fileDownloader.RemoteFile failed, err:", err)
+		return
+	}
+	zipReader, err := zip.NewReader(remoteFile, remoteFile.Size())
+	if err != nil {
+		fmt.Println("2.zip.NewReader failed, err:", err)
+		return
+	}
+	for _, f := range zipReader.File {
+		fmt.Println("2.", f.Name, f.UncompressedSize64)
+	}
+
+	http.ListenAndServe(":8080", nil)
+}
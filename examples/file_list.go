@@ -14,4 +14,14 @@ func main() {
 		return
 	}
 	fmt.Println(res)
+
+	// 按修改时间倒序遍历目录下的所有文件，每页100条
+	it := fileClient.ListIter("/apps/书梯", file.ListOptions{Order: "time", Desc: true, Limit: 100})
+	for it.Next() {
+		fmt.Println(it.Item().ServerFileName)
+	}
+	if err := it.Err(); err != nil {
+		fmt.Println("err:", err)
+		return
+	}
 }
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	fileClient := file.NewFileClient(accessToken)
+
+	// 递归遍历目录下的所有视频，自动翻页
+	it := fileClient.ListAllIter("/apps/书梯", file.ListAllOptions{Category: file.CategoryVideo})
+	for it.Next() {
+		item := it.Item()
+		fmt.Println(item.Path, item.Size)
+	}
+	if err := it.Err(); err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+	"time"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	fileClient := file.NewFileClient(accessToken)
+
+	// 复制文件，目标文件已存在时重命名
+	items := []file.ManagerItem{
+		{Path: "/apps/书梯/test.jpg", Dest: "/apps/书梯/backup", NewName: "test.jpg"},
+	}
+	res, err := fileClient.Copy(items, file.ManagerSync, file.OnDupNewCopy)
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	for _, info := range res.Info {
+		fmt.Println(info.Path, info.Errno)
+	}
+
+	// 异步移动文件，并等待任务执行结束
+	items = []file.ManagerItem{
+		{Path: "/apps/书梯/CHSS.mkv", Dest: "/apps/书梯/video", NewName: "CHSS.mkv"},
+	}
+	res, err = fileClient.Move(items, file.ManagerAsync, file.OnDupOverwrite)
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	taskRes, err := fileClient.WaitTask(ctx, res.TaskID)
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	for _, result := range taskRes.List {
+		fmt.Println(result.From, "=>", result.To)
+	}
+
+	// 删除文件
+	res, err = fileClient.Delete([]string{"/apps/书梯/backup/test.jpg"}, file.ManagerSync)
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(res)
+}
@@ -15,4 +15,14 @@ func main() {
 		return
 	}
 	fmt.Println(res)
+
+	// 只获取文件大小，不返回下载地址和缩略图
+	res, err = fileClient.MetasWithOptions(fsIDs, file.MetasOptions{})
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	for _, item := range res.List {
+		fmt.Println(item.FsID, item.Size)
+	}
 }
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	fileClient := file.NewFileClient(accessToken)
+
+	// 创建单个目录，上级目录需已存在
+	res, err := fileClient.Mkdir("/apps/书梯/backup")
+	if err != nil && res.ErrorCode != file.ErrnoFileExist {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(res)
+
+	// 递归创建目录
+	if err := fileClient.MkdirAll("/apps/书梯/2021/07/26"); err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println("MkdirAll success")
+}
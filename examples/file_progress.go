@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+	fileUtil "github.com/jsyzchen/pan/utils/file"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	path := "/apps/书梯/CHSS.mkv"
+	localFilePath := "/Download/CHSS.mkv"
+
+	// 回调不会并发执行，可以直接更新界面或打印日志
+	onProgress := fileUtil.ProgressFunc(func(p fileUtil.Progress) {
+		percent := 0.0
+		if p.TotalBytes > 0 {
+			percent = float64(p.TransferredBytes) * 100 / float64(p.TotalBytes)
+		}
+		fmt.Printf("%.1f%% %d/%d speed:%.0fKB/s avg:%.0fKB/s\n", percent, p.TransferredBytes, p.TotalBytes, p.Speed/1024, p.AverageSpeed/1024)
+	})
+
+	// 上传进度
+	fileUploader := file.NewUploader(accessToken, path, localFilePath)
+	fileUploader.OnProgress = onProgress
+	res, err := fileUploader.Upload()
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(res)
+
+	// 下载进度
+	fileDownloader := file.NewDownloaderWithPath(accessToken, path, localFilePath)
+	fileDownloader.OnProgress = onProgress
+	if err := fileDownloader.Download(); err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println("download success")
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	fileClient := file.NewFileClient(accessToken)
+
+	// 递归搜索文档，搜索结果可以直接用于Metas、Downloader等
+	it := fileClient.SearchIter("invoice_2023", "/apps/书梯", file.SearchOptions{Recursion: true, Category: file.CategoryDoc})
+	var fsIDs []uint64
+	for it.Next() {
+		fsIDs = append(fsIDs, it.Item().FsID)
+	}
+	if err := it.Err(); err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+
+	res, err := fileClient.Metas(fsIDs)
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(res)
+}
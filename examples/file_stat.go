@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	fileClient := file.NewFileClient(accessToken)
+
+	info, err := fileClient.Stat("/apps/书梯/CHSS.mkv")
+	if errors.Is(err, file.ErrFileNotExist) {
+		fmt.Println("file don't exist")
+		return
+	}
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(info.FsID, info.Size, info.ModTime())
+
+	// 批量获取文件信息，包括下载地址
+	infos, err := fileClient.MetasByPath([]string{"/apps/书梯/CHSS.mkv", "/apps/书梯/test.jpg"})
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	for _, info := range infos {
+		fmt.Println(info.Path, info.DLink)
+	}
+}
@@ -16,4 +16,29 @@ func main() {
 		return
 	}
 	fmt.Println(res)
+
+	// 提前计算文件的md5和分片md5，上传时不再重复读取文件
+	fileInfo, err := file.NewUploader(accessToken, path, localFilePath).ComputeFileInfo()
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fileUploader = file.NewUploader(accessToken, path, localFilePath)
+	fileUploader.FileInfo = &fileInfo
+	res, err = fileUploader.Upload()
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(res)
+
+	// 断点续传，上传进度保存在本地目录，进程重启后再次执行即可继续上传
+	store := file.NewFileUploadStateStore("/tmp/pan_upload_state")
+	fileUploader = file.NewResumableUploader(accessToken, path, localFilePath, store)
+	res, err = fileUploader.Upload()
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(res)
 }
\ No newline at end of file
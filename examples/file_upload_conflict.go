@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	path := "/apps/书梯/backup/db.sql.gz"
+	localFilePath := "/data/backup/db.sql.gz"
+
+	// 备份任务，覆盖网盘中的同名文件
+	fileUploader := file.NewUploader(accessToken, path, localFilePath)
+	fileUploader.OnConflict = file.OnConflictOverwrite
+	res, err := fileUploader.Upload()
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(res.Path)
+
+	// 同步任务，网盘中的同名文件内容不同时才覆盖，相同时跳过上传
+	fileUploader = file.NewUploader(accessToken, path, localFilePath)
+	fileUploader.OnConflict = file.OnConflictOverwriteIfDiff
+	res, err = fileUploader.Upload()
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(res.Path, res.FsID)
+
+	// 网盘已存在同名文件时返回错误
+	fileUploader = file.NewUploader(accessToken, path, localFilePath)
+	fileUploader.OnConflict = file.OnConflictFail
+	res, err = fileUploader.Upload()
+	var conflictErr *file.ConflictError
+	if errors.As(err, &conflictErr) {
+		fmt.Println("file already exists:", conflictErr.Path)
+		return
+	}
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(res.Path)
+}
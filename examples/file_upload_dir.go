@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	localDir := "/Download/project"
+	remoteDir := "/apps/书梯/project"
+
+	fileClient := file.NewFileClient(accessToken)
+	report, err := fileClient.UploadDir(localDir, remoteDir, file.UploadDirOptions{
+		Include: []string{"*.go", "*.md"},
+		Exclude: []string{".git", "vendor", "*_test.go"},
+		Symlink: file.SymlinkFollow,
+		Concurrency: 4,
+		OnFileDone: func(item file.UploadDirItem, status int) {
+			fmt.Println(status, item.Path)
+		},
+	})
+	if err != nil {
+		fmt.Println("err:", err)
+	}
+
+	fmt.Printf("uploaded:%d skipped:%d failed:%d\n", len(report.Uploaded), len(report.Skipped), len(report.Failed))
+	for _, item := range report.Skipped {
+		fmt.Println("skipped:", item.LocalPath, item.Reason)
+	}
+	for _, item := range report.Failed {
+		fmt.Println("failed:", item.LocalPath, item.Err)
+	}
+}
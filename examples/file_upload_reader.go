@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+	"os"
+	"strconv"
+)
+
+// 从标准输入上传，如 mysqldump db | gzip > dump.sql.gz 的内容，需要传入字节数
+// cat dump.sql.gz | go run file_upload_reader.go $(stat -c %s dump.sql.gz)
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	path := "/apps/书梯/dump.sql.gz"
+	size, err := strconv.ParseInt(os.Args[1], 10, 64)
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+
+	fileUploader := file.NewReaderUploader(accessToken, path, os.Stdin, size)
+	fileUploader.SpoolDir = "/data/tmp" // 标准输入不能随机读取，会先缓存到该目录
+	fileUploader.MaxSpoolSize = 10737418240 // 默认最多缓存4G，超过时返回错误，0为不限制
+	res, err := fileUploader.Upload()
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(res)
+}
@@ -0,0 +1,30 @@
+//go:build go1.16
+// +build go1.16
+
+package main
+
+import (
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+	"github.com/jsyzchen/pan/panfs"
+	"io/fs"
+	"net/http"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+	fsys := panfs.New(file.NewFileClient(accessToken), "/apps/书梯")
+
+	// 查找所有的pdf文件
+	matches, err := fs.Glob(fsys, "*.pdf")
+	if err != nil {
+		fmt.Println("err:", err)
+		return
+	}
+	fmt.Println(matches)
+
+	// 通过http访问网盘文件
+	if err := http.ListenAndServe(":8080", http.FileServer(http.FS(fsys))); err != nil {
+		fmt.Println("err:", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jsyzchen/pan/file"
+	"github.com/jsyzchen/pan/utils/ratelimit"
+	"time"
+)
+
+func main() {
+	accessToken := "122.b0a9ab31cc24b429d460cd3ce1f1af97.Yn53jGAwd_1elGgODFvYl1sp9qOYVUDRiVawin5.tbNcEw"
+
+	// 上传和下载共享同一个Limiter，总速度不超过2MB/s
+	limiter := ratelimit.NewLimiter(2 * 1024 * 1024)
+
+	go func() {
+		// 传输过程中可以随时修改限速
+		time.Sleep(time.Minute)
+		limiter.SetLimit(512 * 1024)
+	}()
+
+	done := make(chan error, 2)
+	go func() {
+		fileUploader := file.NewUploader(accessToken, "/apps/书梯/CHSS.mkv", "/Download/CHSS.mkv")
+		fileUploader.Limiter = limiter
+		_, err := fileUploader.Upload()
+		done <- err
+	}()
+	go func() {
+		fileDownloader := file.NewDownloaderWithPath(accessToken, "/apps/书梯/test.jpg", "/Download/test.jpg")
+		fileDownloader.Limiter = limiter
+		done <- fileDownloader.Download()
+	}()
+
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			fmt.Println("err:", err)
+		}
+	}
+
+	// 整个客户端限速，通过fileClient创建的上传、下载以及上传、下载目录都使用该Limiter
+	fileClient := file.NewFileClient(accessToken)
+	fileClient.Limiter = ratelimit.NewLimiter(1024 * 1024)
+	if err := fileClient.NewDownloaderWithPath("/apps/书梯/test.jpg", "/Download/test.jpg").Download(); err != nil {
+		fmt.Println("err:", err)
+	}
+	if _, err := fileClient.DownloadDir("/apps/书梯/photos", "/Download/photos", file.DownloadDirOptions{}); err != nil {
+		fmt.Println("err:", err)
+	}
+}
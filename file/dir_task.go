@@ -0,0 +1,93 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jsyzchen/pan/account"
+	"github.com/jsyzchen/pan/utils/ratelimit"
+	"log"
+	"sync"
+)
+
+// 同时处理的文件数的默认值
+const dirDefaultConcurrency = 4
+
+// 上传、下载目录时单个文件的处理结果，UploadDirUploaded、DownloadDirDownloaded等与之对应
+const (
+	dirFileDone = iota
+	dirFileSkipped
+	dirFileFailed
+)
+
+// 上传、下载目录共用的并发处理和结果汇总
+type dirTask struct {
+	jobs chan func()
+	wg sync.WaitGroup
+	mu sync.Mutex
+	failed int
+}
+
+// 启动concurrency个协程处理文件，concurrency不大于0时为dirDefaultConcurrency
+func newDirTask(concurrency int) *dirTask {
+	if concurrency <= 0 {
+		concurrency = dirDefaultConcurrency
+	}
+
+	t := &dirTask{jobs: make(chan func())}
+	for i := 0; i < concurrency; i++ {
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			for job := range t.jobs {
+				job()
+			}
+		}()
+	}
+	return t
+}
+
+// 交给空闲的协程处理，没有空闲的协程时阻塞
+func (t *dirTask) submit(job func()) {
+	t.jobs <- job
+}
+
+// 等待所有文件处理结束，有文件失败时返回的error说明失败的数量，op为upload或download
+func (t *dirTask) wait(op string) error {
+	close(t.jobs)
+	t.wg.Wait()
+
+	if t.failed > 0 {
+		return errors.New(fmt.Sprintf("%d files %s failed", t.failed, op))
+	}
+	return nil
+}
+
+// 记录单个文件的处理结果，record在锁内执行，用于汇总结果和回调OnFileDone，保证回调不会并发执行
+func (t *dirTask) done(status int, record func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if status == dirFileFailed {
+		t.failed++
+	}
+	record()
+}
+
+// 目录的参数中设置了Limiter时使用该Limiter，否则使用File.Limiter
+func (f *File) limiter(l *ratelimit.Limiter) *ratelimit.Limiter {
+	if l != nil {
+		return l
+	}
+	return f.Limiter
+}
+
+// 只获取一次用户身份，避免每个文件都请求一次，获取失败时按普通用户处理
+func (f *File) dirVipType(ctx context.Context) int {
+	userInfo, err := account.NewAccountClient(f.AccessToken).UserInfoWithContext(ctx)
+	if err != nil {
+		log.Println("account.UserInfo failed, err:", err)
+		return 0
+	}
+	return userInfo.VipType
+}
@@ -1,12 +1,13 @@
 package file
 
 import (
+	"context"
 	"errors"
 	"github.com/jsyzchen/pan/account"
-	"github.com/jsyzchen/pan/conf"
 	"github.com/jsyzchen/pan/utils/file"
+	"github.com/jsyzchen/pan/utils/ratelimit"
+	"io"
 	"log"
-	"net/url"
 )
 
 type Downloader struct {
@@ -16,6 +17,11 @@ type Downloader struct {
 	Path string
 	AccessToken string
 	TotalPart int
+	OnProgress file.ProgressListener //下载进度回调，为空时不回调
+	Limiter *ratelimit.Limiter //下载限速，多个Downloader可以共享同一个Limiter
+	Resumable bool //断点续传，中断后再次下载只下载缺失的部分
+	Verify bool //下载完成后校验md5，需要FsID或Path，不一致时返回*file.ChecksumError
+	MaxRetries int //单个分片下载失败后的最大重试次数，0时使用默认值3，小于0时不重试，分片失败时返回*file.MultiError
 }
 
 const (
@@ -38,7 +44,7 @@ func NewDownloaderWithFsID(accessToken string, fsID uint64, localFilePath string
 	}
 }
 
-// 非开放平台公开接口，生产环境谨慎使用
+// 通过文件路径下载，会先通过Stat获取文件的fsID
 func NewDownloaderWithPath(accessToken string, path string, localFilePath string) *Downloader {
 	return &Downloader{
 		AccessToken: accessToken,
@@ -47,60 +53,184 @@ func NewDownloaderWithPath(accessToken string, path string, localFilePath string
 	}
 }
 
+// 使用File的AccessToken和Limiter创建通过fsID下载的Downloader
+func (f *File) NewDownloaderWithFsID(fsID uint64, localFilePath string) *Downloader {
+	d := NewDownloaderWithFsID(f.AccessToken, fsID, localFilePath)
+	d.Limiter = f.Limiter
+	return d
+}
+
+// 使用File的AccessToken和Limiter创建通过文件路径下载的Downloader
+func (f *File) NewDownloaderWithPath(path string, localFilePath string) *Downloader {
+	d := NewDownloaderWithPath(f.AccessToken, path, localFilePath)
+	d.Limiter = f.Limiter
+	return d
+}
+
 // 执行下载
 func (d *Downloader) Download() error {
-	downloadLink := ""
+	return d.DownloadWithContext(context.Background())
+}
+
+// 执行下载，ctx取消时中断请求
+func (d *Downloader) DownloadWithContext(ctx context.Context) error {
 	if d.LocalFilePath == "" || d.AccessToken == "" {
 		return errors.New("param error, localFilePath is empty")
 	}
 
-	if d.DownloadLink != "" {//直接下载
+	downloader, err := d.newFileDownloader(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := downloader.DownloadWithContext(ctx); err != nil {
+		log.Println("download failed, err:", err)
+		return err
+	}
+
+	return nil
+}
+
+// 按顺序下载到w，不写入LocalFilePath，适合直接写入http响应或者解压等场景，返回写入的字节数
+func (d *Downloader) DownloadTo(ctx context.Context, w io.Writer) (int64, error) {
+	if d.AccessToken == "" {
+		return 0, errors.New("param error, accessToken is empty")
+	}
+
+	downloader, err := d.newFileDownloader(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := downloader.DownloadTo(ctx, w)
+	if err != nil {
+		log.Println("download failed, err:", err)
+		return n, err
+	}
+
+	return n, nil
+}
+
+// 通过Range请求随机读取网盘文件，不下载到本地，返回的RemoteFile实现了io.ReaderAt和io.ReadSeeker
+func (d *Downloader) RemoteFile() (*file.RemoteFile, error) {
+	return d.RemoteFileWithContext(context.Background())
+}
+
+// 通过Range请求随机读取网盘文件，不下载到本地，返回的RemoteFile实现了io.ReaderAt和io.ReadSeeker，ctx取消时中断请求
+func (d *Downloader) RemoteFileWithContext(ctx context.Context) (*file.RemoteFile, error) {
+	if d.AccessToken == "" {
+		return nil, errors.New("param error, accessToken is empty")
+	}
+
+	downloader, err := d.newFileDownloader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return file.NewRemoteFileWithContext(ctx, downloader)
+}
+
+// 获取下载地址，按照Downloader的参数和用户身份创建下载器
+func (d *Downloader) newFileDownloader(ctx context.Context) (*file.Downloader, error) {
+	downloadLink := ""
+	fileClient := NewFileClient(d.AccessToken)
+	fsID := d.FsID
+	fileMd5 := ""
+	if d.DownloadLink != "" && !(d.Verify && (fsID != 0 || d.Path != "")) {//直接下载，需要校验时通过FsID或Path获取md5
 		downloadLink = d.DownloadLink
-	} else if d.FsID != 0 {
-		// 根据fsID获取下载链接
-		fileClient := NewFileClient(d.AccessToken)
-		metas, err := fileClient.Metas([]uint64{d.FsID})
+	} else if fsID != 0 || d.Path != "" {
+		if fsID == 0 {
+			// 根据文件路径获取fsID
+			info, err := fileClient.StatWithContext(ctx, d.Path)
+			if err != nil {
+				log.Println("fileClient.Stat failed, err:", err)
+				return nil, err
+			}
+			if info.IsDir {
+				return nil, errors.New("param error, path is a directory")
+			}
+			fsID = info.FsID
+		}
+		// 根据fsID获取下载链接和md5
+		meta, err := fileClient.downloadMeta(ctx, fsID)
 		if err != nil {
-			log.Println("fileClient.Metas failed, err:", err)
-			return err
+			return nil, err
 		}
-		if len(metas.List) == 0 {
-			log.Println("file don't exist")
-			return errors.New("file don't exist")
+		downloadLink, fileMd5 = meta.DLink, meta.Md5
+		if d.DownloadLink != "" {
+			downloadLink = d.DownloadLink
 		}
-		downloadLink = metas.List[0].DLink
-	} else if d.Path != "" { // TODO 如何通过文件路径获取下载地址
-		v := url.Values{}
-		v.Add("path", d.Path)
-		v.Add("access_token", d.AccessToken)
-		body := v.Encode()
-		downloadLink = conf.PcsApiDomain + PcsFileDownloadUri + "&" + body
 	} else {
-		return errors.New("param error")
+		return nil, errors.New("param error")
 	}
 
 	if downloadLink == "" {
-		return errors.New("param error, downloadLink is empty")
+		return nil, errors.New("param error, downloadLink is empty")
+	}
+	if d.Verify && fileMd5 == "" {
+		return nil, errors.New("param error, md5 is unknown, Verify needs FsID or Path")
 	}
 
 	downloadLink += "&access_token=" + d.AccessToken
 	downloader := file.NewFileDownloader(downloadLink, d.LocalFilePath)
+	downloader.OnProgress = d.OnProgress
+	downloader.Limiter = d.Limiter
+	downloader.Resumable = d.Resumable
+	downloader.MaxRetries = d.MaxRetries
+	if d.Verify {
+		downloader.Md5 = fileMd5
+	}
+	if fsID != 0 {//下载地址有效期为8小时，失效后通过fsID重新获取
+		downloader.RefreshLink = fileClient.refreshLinkFunc(ctx, fsID)
+	}
 
 	accountClient := account.NewAccountClient(d.AccessToken)
-	if userInfo, err := accountClient.UserInfo(); err == nil {
+	if userInfo, err := accountClient.UserInfoWithContext(ctx); err == nil {
 		log.Println("VipType:", userInfo.VipType)
-		if userInfo.VipType == 2 { //当前用户是超级会员
-			downloader.SetPartSize(52428800) //设置每分片下载文件大小，50M
-			downloader.SetCoroutineNum(10) //分片下载并发数，普通用户不支持并发分片下载
-		}
+		setDownloaderByVipType(downloader, userInfo.VipType)
 	}
 
-	if err := downloader.Download(); err != nil {
-		log.Println("download failed, err:", err)
-		return err
+	return downloader, nil
+}
+
+// 根据用户身份设置分片大小和并发数
+func setDownloaderByVipType(downloader *file.Downloader, vipType int) {
+	if vipType == 2 { //当前用户是超级会员
+		downloader.SetPartSize(52428800) //设置每分片下载文件大小，50M
+		downloader.SetCoroutineNum(10) //分片下载并发数，普通用户不支持并发分片下载
 	}
+}
 
-	return nil
+// 根据fsID获取下载链接
+func (f *File) downloadLink(ctx context.Context, fsID uint64) (string, error) {
+	meta, err := f.downloadMeta(ctx, fsID)
+	if err != nil {
+		return "", err
+	}
+	return meta.DLink, nil
 }
 
+// 根据fsID获取包含下载链接的文件信息
+func (f *File) downloadMeta(ctx context.Context, fsID uint64) (MetasItem, error) {
+	metas, err := f.MetasWithOptionsWithContext(ctx, []uint64{fsID}, MetasOptions{DLink: true})
+	if err != nil {
+		log.Println("fileClient.Metas failed, err:", err)
+		return MetasItem{}, err
+	}
+	if len(metas.List) == 0 {
+		log.Println("file don't exist")
+		return MetasItem{}, ErrFileNotExist
+	}
+	return metas.List[0], nil
+}
 
+// 下载地址失效时重新获取，返回的地址已带有access_token
+func (f *File) refreshLinkFunc(ctx context.Context, fsID uint64) func() (string, error) {
+	return func() (string, error) {
+		link, err := f.downloadLink(ctx, fsID)
+		if err != nil {
+			return "", err
+		}
+		return link + "&access_token=" + f.AccessToken, nil
+	}
+}
@@ -0,0 +1,353 @@
+package file
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/jsyzchen/pan/utils"
+	fileUtil "github.com/jsyzchen/pan/utils/file"
+	"github.com/jsyzchen/pan/utils/ratelimit"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 下载目录的参数
+type DownloadDirOptions struct {
+	Include []string //只下载匹配的文件，glob格式，匹配相对路径或文件名，为空时下载所有文件
+	Exclude []string //不下载匹配的文件和目录，glob格式，匹配相对路径或文件名，优先于Include
+	Concurrency int //同时下载的文件数，默认为4
+	ForceDownload bool //为true时本地已存在大小和md5相同的文件也重新下载
+	Verify bool //下载完成后校验md5，不一致时该文件下载失败
+	Resumable bool //断点续传，中断后再次下载目录时已下载的分片不再下载
+	MaxRetries int //单个分片下载失败后的最大重试次数
+	Limiter *ratelimit.Limiter //下载限速，限制的是所有文件的总速度，为空时使用File.Limiter
+	OnFileDone func(item DownloadDirItem, status int) //每个文件处理结束后回调，status为DownloadDirDownloaded等，不会并发执行
+}
+
+// 目录中单个文件的处理结果
+const (
+	DownloadDirDownloaded = dirFileDone
+	DownloadDirSkipped = dirFileSkipped
+	DownloadDirFailed = dirFileFailed
+)
+
+// 目录中单个文件或目录的下载结果
+type DownloadDirItem struct {
+	Path string //网盘路径
+	LocalPath string
+	FsID uint64
+	Size int64
+	Md5 string
+	ServerMtime int
+	Reason string //跳过的原因
+	Err error //失败的原因
+	dLink string
+}
+
+// 下载目录的结果汇总
+type DownloadDirReport struct {
+	Downloaded []DownloadDirItem
+	Skipped []DownloadDirItem
+	Failed []DownloadDirItem
+}
+
+// 下载网盘目录到本地，目录结构保持不变，包括空目录，文件的修改时间设置为网盘的server_mtime
+// 单个文件失败不影响其他文件，所有文件处理结束后返回的error说明失败的数量，每个文件的结果在DownloadDirReport中
+func (f *File) DownloadDir(remoteDir, localDir string, opts DownloadDirOptions) (DownloadDirReport, error) {
+	return f.DownloadDirWithContext(context.Background(), remoteDir, localDir, opts)
+}
+
+// 下载网盘目录到本地，目录结构保持不变，包括空目录，文件的修改时间设置为网盘的server_mtime，ctx取消时中断请求
+// 单个文件失败不影响其他文件，所有文件处理结束后返回的error说明失败的数量，每个文件的结果在DownloadDirReport中
+func (f *File) DownloadDirWithContext(ctx context.Context, remoteDir, localDir string, opts DownloadDirOptions) (DownloadDirReport, error) {
+	report := DownloadDirReport{}
+
+	remoteDir = path.Clean("/" + remoteDir)
+	info, err := f.StatWithContext(ctx, remoteDir)
+	if err != nil {
+		log.Println("Stat failed, err:", err)
+		return report, err
+	}
+	if !info.IsDir {
+		return report, errors.New(fmt.Sprintf("remoteDir[%s] is not a directory", remoteDir))
+	}
+
+	if err := os.MkdirAll(localDir, os.ModePerm); err != nil {
+		return report, err
+	}
+
+	w := &dirDownloader{
+		file: f,
+		opts: opts,
+		task: newDirTask(opts.Concurrency),
+		report: &report,
+		vipType: f.dirVipType(ctx),
+	}
+
+	listErr := w.walk(ctx, remoteDir, localDir)
+	failedErr := w.task.wait("download")
+
+	// 目录中的文件都下载完成后再设置目录的修改时间
+	for _, dir := range w.dirs {
+		mtime := time.Unix(int64(dir.ServerMtime), 0)
+		if err := os.Chtimes(dir.LocalPath, mtime, mtime); err != nil {
+			log.Println("os.Chtimes failed, err:", err)
+		}
+	}
+
+	if listErr != nil {
+		return report, listErr
+	}
+
+	return report, failedErr
+}
+
+type dirDownloader struct {
+	file *File
+	opts DownloadDirOptions
+	task *dirTask
+	report *DownloadDirReport
+	vipType int
+	dirs []DownloadDirItem
+}
+
+// 递归列出网盘目录，创建本地目录，需要下载的文件每metasMaxFsIDs个批量获取下载地址后交给task下载
+func (w *dirDownloader) walk(ctx context.Context, remoteDir, localDir string) error {
+	var excludedDirs []string
+	var batch []DownloadDirItem
+
+	it := w.file.ListAllIterWithContext(ctx, remoteDir, ListAllOptions{})
+	for it.Next() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		listItem := it.Item()
+		rel := strings.TrimPrefix(strings.TrimPrefix(listItem.Path, remoteDir), "/")
+		if rel == "" || hasPathPrefix(rel, excludedDirs) {
+			continue
+		}
+
+		item := DownloadDirItem{
+			Path: listItem.Path,
+			LocalPath: filepath.Join(localDir, filepath.FromSlash(rel)),
+			FsID: listItem.FsID,
+			Size: int64(listItem.Size),
+			Md5: utils.DecryptMd5(listItem.Md5),
+			ServerMtime: listItem.ServerMtime,
+		}
+
+		if matchAny(w.opts.Exclude, rel, listItem.ServerFileName) {
+			if listItem.IsDir == 1 {
+				excludedDirs = append(excludedDirs, rel)
+			}
+			w.skip(item, SkipReasonExcluded)
+			continue
+		}
+
+		if listItem.IsDir == 1 {
+			if err := os.MkdirAll(item.LocalPath, os.ModePerm); err != nil {
+				w.fail(item, err)
+				continue
+			}
+			w.dirs = append(w.dirs, item)
+			continue
+		}
+
+		if len(w.opts.Include) > 0 && !matchAny(w.opts.Include, rel, listItem.ServerFileName) {
+			w.skip(item, SkipReasonNotIncluded)
+			continue
+		}
+
+		// 空文件不需要下载地址，本地已有相同大小的文件时在task中计算md5判断是否需要下载，避免阻塞遍历
+		if item.Size == 0 || (!w.opts.ForceDownload && isSameLocalSize(item)) {
+			w.submit(ctx, item)
+			continue
+		}
+
+		batch = append(batch, item)
+		if len(batch) == metasMaxFsIDs {
+			w.dispatch(ctx, batch)
+			batch = nil
+		}
+	}
+	w.dispatch(ctx, batch)
+
+	// 已经列出的文件仍然会下载完成
+	if err := it.Err(); err != nil {
+		log.Println("ListAllIter failed, err:", err)
+		return err
+	}
+
+	return nil
+}
+
+// 批量获取下载地址，交给task下载
+func (w *dirDownloader) dispatch(ctx context.Context, batch []DownloadDirItem) {
+	if len(batch) == 0 {
+		return
+	}
+
+	fsIDs := make([]uint64, len(batch))
+	for i, item := range batch {
+		fsIDs[i] = item.FsID
+	}
+	metas, err := w.file.MetasWithOptionsWithContext(ctx, fsIDs, MetasOptions{DLink: true})
+	if err != nil {
+		log.Println("MetasWithOptions failed, err:", err)
+		for _, item := range batch {
+			w.fail(item, err)
+		}
+		return
+	}
+
+	dLinks := make(map[uint64]string, len(metas.List))
+	for _, meta := range metas.List {
+		dLinks[meta.FsID] = meta.DLink
+	}
+	for _, item := range batch {
+		item.dLink = dLinks[item.FsID]
+		if item.dLink == "" {
+			w.fail(item, ErrFileNotExist)
+			continue
+		}
+		w.submit(ctx, item)
+	}
+}
+
+// 下载单个文件，并将修改时间设置为网盘的server_mtime
+func (w *dirDownloader) downloadFile(ctx context.Context, item DownloadDirItem) {
+	if !w.opts.ForceDownload && isSameLocalFile(item) {
+		w.skip(item, SkipReasonUnchanged)
+		return
+	}
+	if item.Size > 0 && item.dLink == "" {//本地文件的md5不同，单独获取下载地址
+		dLink, err := w.file.downloadLink(ctx, item.FsID)
+		if err != nil {
+			w.fail(item, err)
+			return
+		}
+		item.dLink = dLink
+	}
+
+	if err := os.MkdirAll(filepath.Dir(item.LocalPath), os.ModePerm); err != nil {
+		w.fail(item, err)
+		return
+	}
+
+	if item.Size == 0 {
+		emptyFile, err := os.Create(item.LocalPath)
+		if err != nil {
+			w.fail(item, err)
+			return
+		}
+		emptyFile.Close()
+	} else {
+		downloader := fileUtil.NewFileDownloader(item.dLink+"&access_token="+w.file.AccessToken, item.LocalPath)
+		setDownloaderByVipType(downloader, w.vipType)
+		downloader.Limiter = w.file.limiter(w.opts.Limiter)
+		downloader.Resumable = w.opts.Resumable
+		downloader.MaxRetries = w.opts.MaxRetries
+		downloader.RefreshLink = w.file.refreshLinkFunc(ctx, item.FsID)
+		if w.opts.Verify {
+			downloader.Md5 = item.Md5
+		}
+		if err := downloader.DownloadWithContext(ctx); err != nil {
+			w.fail(item, err)
+			return
+		}
+	}
+
+	mtime := time.Unix(int64(item.ServerMtime), 0)
+	if err := os.Chtimes(item.LocalPath, mtime, mtime); err != nil {
+		log.Println("os.Chtimes failed, err:", err)
+	}
+
+	w.done(item, DownloadDirDownloaded)
+}
+
+func (w *dirDownloader) submit(ctx context.Context, item DownloadDirItem) {
+	w.task.submit(func() {
+		w.downloadFile(ctx, item)
+	})
+}
+
+func (w *dirDownloader) skip(item DownloadDirItem, reason string) {
+	item.Reason = reason
+	w.done(item, DownloadDirSkipped)
+}
+
+func (w *dirDownloader) fail(item DownloadDirItem, err error) {
+	log.Printf("download failed, path[%s] err[%v]", item.Path, err)
+	item.Err = err
+	w.done(item, DownloadDirFailed)
+}
+
+func (w *dirDownloader) done(item DownloadDirItem, status int) {
+	w.task.done(status, func() {
+		switch status {
+		case DownloadDirDownloaded:
+			w.report.Downloaded = append(w.report.Downloaded, item)
+		case DownloadDirSkipped:
+			w.report.Skipped = append(w.report.Skipped, item)
+		default:
+			w.report.Failed = append(w.report.Failed, item)
+		}
+
+		if w.opts.OnFileDone != nil {
+			w.opts.OnFileDone(item, status)
+		}
+	})
+}
+
+// 本地文件的大小和md5是否与网盘文件相同
+func isSameLocalFile(item DownloadDirItem) bool {
+	if !isSameLocalSize(item) {
+		return false
+	}
+
+	localMd5, err := fileMd5(item.LocalPath)
+	if err != nil {
+		return false
+	}
+
+	return localMd5 == item.Md5
+}
+
+// 本地是否已有大小相同的文件，只有大小相同时才需要计算md5
+func isSameLocalSize(item DownloadDirItem) bool {
+	stat, err := os.Stat(item.LocalPath)
+	return err == nil && stat.Mode().IsRegular() && stat.Size() == item.Size
+}
+
+// 计算本地文件的md5
+func fileMd5(localFilePath string) (string, error) {
+	file, err := os.Open(localFilePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// rel是否在dirs中的某个目录下
+func hasPathPrefix(rel string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(rel, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
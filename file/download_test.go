@@ -1,7 +1,15 @@
 package file
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"github.com/jsyzchen/pan/conf"
+	fileUtil "github.com/jsyzchen/pan/utils/file"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -25,5 +33,106 @@ func TestDownloaderWithPath(t *testing.T) {
 	}
 }
 
+func TestDownloader_OnProgress(t *testing.T) {
+	fileDownloader := NewDownloaderWithFsID(conf.TestData.AccessToken, conf.TestData.FsID, conf.TestData.LocalFilePath)
+	fileDownloader.OnProgress = fileUtil.ProgressFunc(func(p fileUtil.Progress) {
+		t.Logf("TestDownloader_OnProgress %d/%d, speed:%.0f", p.TransferredBytes, p.TotalBytes, p.Speed)
+	})
+	if err := fileDownloader.Download(); err != nil {
+		t.Errorf("TestDownloader_OnProgress failed, err:%v", err)
+	}
+}
+
+func TestFile_DownloadDir(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	localDir := filepath.Join(filepath.Dir(conf.TestData.LocalFilePath), "pan_download_dir")
+	report, err := fileClient.DownloadDir(conf.TestData.Dir, localDir, DownloadDirOptions{})
+	if err != nil {
+		t.Errorf("TestFile_DownloadDir failed, err:%v", err)
+	}
+	t.Logf("TestFile_DownloadDir downloaded:%d skipped:%d failed:%d", len(report.Downloaded), len(report.Skipped), len(report.Failed))
+}
+
+func TestDownloader_Resumable(t *testing.T) {
+	fileDownloader := NewDownloaderWithFsID(conf.TestData.AccessToken, conf.TestData.FsID, conf.TestData.LocalFilePath)
+	fileDownloader.Resumable = true
+	if err := fileDownloader.Download(); err != nil {
+		t.Errorf("TestDownloader_Resumable failed, err:%v", err)
+	}
+}
+
+func TestDownloader_Verify(t *testing.T) {
+	fileDownloader := NewDownloaderWithFsID(conf.TestData.AccessToken, conf.TestData.FsID, conf.TestData.LocalFilePath)
+	fileDownloader.Verify = true
+	err := fileDownloader.Download()
+	var checksumErr *fileUtil.ChecksumError
+	if errors.As(err, &checksumErr) {
+		t.Errorf("TestDownloader_Verify checksum mismatch, err:%v", checksumErr)
+	} else if err != nil {
+		t.Errorf("TestDownloader_Verify failed, err:%v", err)
+	}
+}
+
+func TestDownloader_MaxRetries(t *testing.T) {
+	fileDownloader := NewDownloaderWithFsID(conf.TestData.AccessToken, conf.TestData.FsID, conf.TestData.LocalFilePath)
+	fileDownloader.MaxRetries = 5
+	err := fileDownloader.Download()
+	var multiErr *fileUtil.MultiError
+	if errors.As(err, &multiErr) {
+		t.Errorf("TestDownloader_MaxRetries parts %v failed, err:%v", multiErr.Parts(), multiErr)
+	} else if err != nil {
+		t.Errorf("TestDownloader_MaxRetries failed, err:%v", err)
+	}
+}
+
+func TestDownloader_DownloadTo(t *testing.T) {
+	fileDownloader := NewDownloaderWithFsID(conf.TestData.AccessToken, conf.TestData.FsID, "")
+	fileDownloader.Verify = true
+	var buf bytes.Buffer
+	n, err := fileDownloader.DownloadTo(context.Background(), &buf)
+	if err != nil {
+		t.Errorf("TestDownloader_DownloadTo failed, err:%v", err)
+	} else if n != int64(buf.Len()) {
+		t.Errorf("TestDownloader_DownloadTo length mismatch, n:%d len:%d", n, buf.Len())
+	}
+}
 
+func TestDownloader_RemoteFile(t *testing.T) {
+	remoteFile, err := NewDownloaderWithFsID(conf.TestData.AccessToken, conf.TestData.FsID, "").RemoteFile()
+	if err != nil {
+		t.Fatalf("TestDownloader_RemoteFile failed, err:%v", err)
+	}
+
+	all, err := ioutil.ReadAll(remoteFile)
+	if err != nil || int64(len(all)) != remoteFile.Size() {
+		t.Fatalf("TestDownloader_RemoteFile ReadAll failed, len:%d err:%v", len(all), err)
+	}
 
+	off := remoteFile.Size() / 2
+	p := make([]byte, remoteFile.Size()-off)
+	if _, err := remoteFile.ReadAt(p, off); err != nil && err != io.EOF {
+		t.Fatalf("TestDownloader_RemoteFile ReadAt failed, err:%v", err)
+	}
+	if !bytes.Equal(p, all[off:]) {
+		t.Errorf("TestDownloader_RemoteFile ReadAt content mismatch")
+	}
+}
+
+func TestDownloader_DownloadWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	localFilePath := filepath.Join(dir, "test.bin")
+	fileDownloader := NewDownloaderWithFsID(conf.TestData.AccessToken, conf.TestData.FsID, localFilePath)
+	err = fileDownloader.DownloadWithContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("TestDownloader_DownloadWithContext expected context.Canceled, err:%v", err)
+	}
+	if matches, _ := filepath.Glob(localFilePath + "*"); len(matches) > 0 {
+		t.Errorf("TestDownloader_DownloadWithContext temp files not removed: %v", matches)
+	}
+}
@@ -1,39 +1,159 @@
 package file
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jsyzchen/pan/conf"
 	"github.com/jsyzchen/pan/utils/httpclient"
+	"github.com/jsyzchen/pan/utils/ratelimit"
 	"log"
 	"net/url"
+	"path"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 const (
 	ListUri = "/rest/2.0/xpan/file?method=list"
+	ListAllUri = "/rest/2.0/xpan/multimedia?method=listall"
+	SearchUri = "/rest/2.0/xpan/file?method=search"
+	CategoryInfoUri = "/api/categoryinfo"
+	CategoryListUri = "/rest/2.0/xpan/multimedia?method=categorylist"
 	MetasUri = "/rest/2.0/xpan/multimedia?method=filemetas"
 	StreamingUri = "/rest/2.0/xpan/file?method=streaming"
 )
 
+// 文件分类
+const (
+	CategoryVideo = 1 //视频
+	CategoryAudio = 2 //音频
+	CategoryImage = 3 //图片
+	CategoryDoc = 4 //文档
+	CategoryApp = 5 //应用
+	CategoryOther = 6 //其他
+	CategoryBT = 7 //种子
+)
+
+// list每页默认数量
+const listDefaultLimit = 1000
+
+// search每页默认数量
+const searchDefaultNum = 500
+
+// filemetas每次请求最多的fsid数量以及分批请求的并发数
+const (
+	metasMaxFsIDs = 100
+	metasCoroutineNum = 4
+)
+
+// listall每页最大数量
+const listAllMaxLimit = 1000
+
+// 网盘接口错误码
+const (
+	ErrnoFileExist = -8 //文件或目录已存在
+	ErrnoFileNotExist = -9 //文件或目录不存在
+)
+
 type ListResponse struct {
 	conf.CloudDiskResponseBase
-	List []struct {
-		FsID 	uint64 `json:"fs_id"`
-		Path      string `json:"path"`
-		ServerFileName string `json:"server_filename"`
-		Size      int    `json:"size"`
-		IsDir    int    `json:"isdir"`
-		Category    int    `json:"category"`
-		Md5       string `json:"md5"`
-		DirEmpty string `json:"dir_empty"`
-		Thumbs map[string]string `json:"thumbs"`
-		LocalCtime     int    `json:"local_ctime"`
-		LocalMtime     int    `json:"local_mtime"`
-		ServerCtime     int    `json:"server_ctime"`
-		ServerMtime     int    `json:"server_mtime"`
-	}
+	List []ListItem
+}
+
+type ListAllResponse struct {
+	conf.CloudDiskResponseBase
+	HasMore int `json:"has_more"`
+	Cursor int `json:"cursor"`
+	List []ListItem `json:"list"`
+}
+
+type SearchResponse struct {
+	conf.CloudDiskResponseBase
+	HasMore int `json:"has_more"`
+	List []ListItem `json:"list"`
+}
+
+type CategoryListResponse struct {
+	conf.CloudDiskResponseBase
+	HasMore int `json:"has_more"`
+	Cursor int `json:"cursor"`
+	List []ListItem `json:"list"`
+}
+
+type CategoryInfoResponse struct {
+	conf.CloudDiskResponseBase
+	Info map[int]CategoryStat `json:"info"` //key为文件分类
+}
+
+// 单个分类的文件统计
+type CategoryStat struct {
+	Total int `json:"total"`
+	Size int64 `json:"size"`
+	Count int `json:"count"`
+}
+
+// 文件列表中的单个文件或目录
+type ListItem struct {
+	FsID 	uint64 `json:"fs_id"`
+	Path      string `json:"path"`
+	ServerFileName string `json:"server_filename"`
+	Size      int    `json:"size"`
+	IsDir    int    `json:"isdir"`
+	Category    int    `json:"category"`
+	Md5       string `json:"md5"`
+	DirEmpty int `json:"dir_empty"` //web=1且为目录时返回，0为存在子目录，1为不存在
+	Thumbs map[string]string `json:"thumbs"`
+	LocalCtime     int    `json:"local_ctime"`
+	LocalMtime     int    `json:"local_mtime"`
+	ServerCtime     int    `json:"server_ctime"`
+	ServerMtime     int    `json:"server_mtime"`
+}
+
+// 获取文件列表的参数
+type ListOptions struct {
+	Order string // 排序字段：name、time、size，默认为name
+	Desc bool // 是否降序
+	Folder bool // 只返回目录
+	ShowEmpty bool // 返回目录是否为空
+	Web bool // 返回缩略图和dir_empty
+	Limit int // ListIter每页数量，默认为1000
+}
+
+// 递归获取文件列表的参数
+// listall接口不支持Category、MtimeFrom、MtimeTo、DirOnly，这些条件在本地过滤，仍然会翻页请求整个目录树
+type ListAllOptions struct {
+	Order string // 排序字段：name、time、size，默认为name
+	Desc bool // 是否降序
+	Limit int // 每页数量，默认为1000，最大为1000
+	Web bool // 是否返回缩略图
+	Category int // 本地过滤，只返回该分类的文件，0为不限制
+	MtimeFrom int // 本地过滤，只返回server_mtime不小于该值的文件，0为不限制
+	MtimeTo int // 本地过滤，只返回server_mtime不大于该值的文件，0为不限制
+	DirOnly bool // 本地过滤，只返回目录
+}
+
+// 搜索文件的参数
+type SearchOptions struct {
+	Recursion bool // 是否递归搜索子目录
+	Category int // 只搜索该分类的文件，0为不限制
+	Page int // 页码，从1开始，默认为1，SearchIter不生效
+	Num int // 每页数量，默认为500，最大为1000
+	Web bool // 返回缩略图
+}
+
+// 按分类获取文件列表的参数
+type CategoryListOptions struct {
+	Dir string // 目录，默认为根目录
+	Recursion bool // 是否递归子目录
+	Ext []string // 只返回这些扩展名的文件，如 []string{"mp4", "mkv"}
+	ShowDir bool // 是否返回目录
+	Order string // 排序字段：name、time、size，默认为name
+	Desc bool // 是否降序
+	Start int // 起始位置，CategoryListIter不生效
+	Limit int // 每页数量，默认为1000，最大为1000
 }
 
 type MetasResponse struct {
@@ -41,35 +161,53 @@ type MetasResponse struct {
 	ErrorMsg  string `json:"errmsg"`
 	RequestID int
 	RequestIDStr string `json:"request_id"`
-	List []struct {
-		FsID 	uint64 `json:"fs_id"`
-		Path      string `json:"path"`
-		Category    int    `json:"category"`
-		FileName string `json:"filename"`
-		IsDir    int    `json:"isdir"`
-		Size      int    `json:"size"`
-		Md5       string `json:"md5"`
-		DLink string `json:"dlink"`
-		Thumbs map[string]string `json:"thumbs"`
-		ServerCtime     int    `json:"server_ctime"`
-		ServerMtime     int    `json:"server_mtime"`
-		DateTaken int `json:"date_taken"`
-		Width int `json:"width"`
-		Height int `json:"height"`
-	}
+	List []MetasItem
+}
+
+// 通过FsID获取的文件信息
+type MetasItem struct {
+	FsID 	uint64 `json:"fs_id"`
+	Path      string `json:"path"`
+	Category    int    `json:"category"`
+	FileName string `json:"filename"`
+	IsDir    int    `json:"isdir"`
+	Size      int    `json:"size"`
+	Md5       string `json:"md5"`
+	DLink string `json:"dlink"`
+	Thumbs map[string]string `json:"thumbs"`
+	ServerCtime     int    `json:"server_ctime"`
+	ServerMtime     int    `json:"server_mtime"`
+	DateTaken int `json:"date_taken"`
+	Width int `json:"width"`
+	Height int `json:"height"`
+}
+
+// 获取文件信息的参数
+type MetasOptions struct {
+	DLink bool // 返回下载地址
+	Thumb bool // 返回缩略图
+	Extra bool // 返回图片、视频的额外信息
+	NeedMedia bool // 返回是否为多媒体文件
 }
 
 type ManagerResponse struct {
 	conf.CloudDiskResponseBase
-	Info []struct{
-		Path string
-		TaskID int
-		Errno int
-	}
+	TaskID int `json:"taskid"` //异步执行时返回
+	Info []ManagerInfo `json:"info"`
+}
+
+// 文件操作每个条目的执行结果
+type ManagerInfo struct {
+	Path string `json:"path"`
+	TaskID int `json:"taskid"`
+	Errno int `json:"errno"`
 }
 
 type File struct {
 	AccessToken string
+	Limiter *ratelimit.Limiter //通过File创建的上传、下载以及UploadDir、DownloadDir共享的限速，为空时不限速
+	statMu sync.Mutex
+	statCache map[string]*statCacheEntry //Stat缓存的目录列表，key为目录路径
 }
 
 func NewFileClient(accessToken string) *File {
@@ -80,6 +218,21 @@ func NewFileClient(accessToken string) *File {
 
 // 获取文件列表
 func (f *File) List(dir string, start, limit int) (ListResponse, error) {
+	return f.ListWithContext(context.Background(), dir, start, limit)
+}
+
+// 获取文件列表，ctx取消时中断请求
+func (f *File) ListWithContext(ctx context.Context, dir string, start, limit int) (ListResponse, error) {
+	return f.ListWithOptionsWithContext(ctx, dir, start, limit, ListOptions{})
+}
+
+// 获取文件列表，支持排序、只返回目录、返回缩略图等参数，opts.Limit不生效
+func (f *File) ListWithOptions(dir string, start, limit int, opts ListOptions) (ListResponse, error) {
+	return f.ListWithOptionsWithContext(context.Background(), dir, start, limit, opts)
+}
+
+// 获取文件列表，支持排序、只返回目录、返回缩略图等参数，opts.Limit不生效，ctx取消时中断请求
+func (f *File) ListWithOptionsWithContext(ctx context.Context, dir string, start, limit int, opts ListOptions) (ListResponse, error) {
 	ret := ListResponse{}
 
 	v := url.Values{}
@@ -87,10 +240,25 @@ func (f *File) List(dir string, start, limit int) (ListResponse, error) {
 	v.Add("dir", dir)
 	v.Add("start", strconv.Itoa(start))
 	v.Add("limit", strconv.Itoa(limit))
+	if opts.Order != "" {
+		v.Add("order", opts.Order)
+	}
+	if opts.Desc {
+		v.Add("desc", "1")
+	}
+	if opts.Folder {
+		v.Add("folder", "1")
+	}
+	if opts.ShowEmpty {
+		v.Add("showempty", "1")
+	}
+	if opts.Web {
+		v.Add("web", "1")
+	}
 	query := v.Encode()
 
 	requestUrl := conf.OpenApiDomain + ListUri + "&" + query
-	resp, err := httpclient.Get(requestUrl, map[string]string{})
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
 	if err != nil {
 		log.Println("httpclient.Get failed, err:", err)
 		return ret, err
@@ -111,8 +279,423 @@ func (f *File) List(dir string, start, limit int) (ListResponse, error) {
 	return ret, nil
 }
 
-// 通过FsID获取文件信息
+// 遍历目录下的文件，自动翻页，返回数量小于每页数量时结束
+func (f *File) ListIter(dir string, opts ListOptions) *ListIterator {
+	return f.ListIterWithContext(context.Background(), dir, opts)
+}
+
+// 遍历目录下的文件，自动翻页，返回数量小于每页数量时结束，ctx取消时中断请求
+func (f *File) ListIterWithContext(ctx context.Context, dir string, opts ListOptions) *ListIterator {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = listDefaultLimit
+	}
+
+	return newListIterator(func(start int) ([]ListItem, int, bool, error) {
+		res, err := f.ListWithOptionsWithContext(ctx, dir, start, limit, opts)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		return res.List, start + len(res.List), len(res.List) >= limit, nil
+	})
+}
+
+// 按文件名搜索文件，dir为空时搜索根目录
+func (f *File) Search(key, dir string, opts SearchOptions) (SearchResponse, error) {
+	return f.SearchWithContext(context.Background(), key, dir, opts)
+}
+
+// 按文件名搜索文件，dir为空时搜索根目录，ctx取消时中断请求
+func (f *File) SearchWithContext(ctx context.Context, key, dir string, opts SearchOptions) (SearchResponse, error) {
+	ret := SearchResponse{}
+
+	page := opts.Page
+	if page <= 0 {
+		page = 1
+	}
+	num := opts.Num
+	if num <= 0 {
+		num = searchDefaultNum
+	}
+
+	v := url.Values{}
+	v.Add("access_token", f.AccessToken)
+	v.Add("key", key)
+	if dir != "" {
+		v.Add("dir", dir)
+	}
+	v.Add("page", strconv.Itoa(page))
+	v.Add("num", strconv.Itoa(num))
+	if opts.Recursion {
+		v.Add("recursion", "1")
+	}
+	if opts.Category != 0 {
+		v.Add("category", strconv.Itoa(opts.Category))
+	}
+	if opts.Web {
+		v.Add("web", "1")
+	}
+	query := v.Encode()
+
+	requestUrl := conf.OpenApiDomain + SearchUri + "&" + query
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
+	if err != nil {
+		log.Println("httpclient.Get failed, err:", err)
+		return ret, err
+	}
+
+	if resp.StatusCode != 200 {
+		return ret, errors.New(fmt.Sprintf("HttpStatusCode is not equal to 200, httpStatusCode[%d], respBody[%s]", resp.StatusCode, string(resp.Body)))
+	}
+
+	if err := json.Unmarshal(resp.Body, &ret); err != nil {
+		return ret, err
+	}
+
+	if ret.ErrorCode != 0 {//错误码不为0
+		return ret, errors.New(fmt.Sprintf("error_code:%d, error_msg:%s", ret.ErrorCode, ret.ErrorMsg))
+	}
+
+	return ret, nil
+}
+
+// 遍历搜索结果，自动翻页
+func (f *File) SearchIter(key, dir string, opts SearchOptions) *ListIterator {
+	return f.SearchIterWithContext(context.Background(), key, dir, opts)
+}
+
+// 遍历搜索结果，自动翻页，ctx取消时中断请求
+func (f *File) SearchIterWithContext(ctx context.Context, key, dir string, opts SearchOptions) *ListIterator {
+	return newListIterator(func(start int) ([]ListItem, int, bool, error) {
+		pageOpts := opts
+		pageOpts.Page = start + 1
+		res, err := f.SearchWithContext(ctx, key, dir, pageOpts)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		return res.List, start + 1, res.HasMore == 1, nil
+	})
+}
+
+// 获取目录下各分类的文件数量和大小，包括子目录
+func (f *File) CategoryInfo(dir string) (CategoryInfoResponse, error) {
+	return f.CategoryInfoWithContext(context.Background(), dir)
+}
+
+// 获取目录下各分类的文件数量和大小，包括子目录，ctx取消时中断请求
+func (f *File) CategoryInfoWithContext(ctx context.Context, dir string) (CategoryInfoResponse, error) {
+	ret := CategoryInfoResponse{
+		Info: make(map[int]CategoryStat),
+	}
+
+	// 接口每次只能查询一个分类
+	for category := CategoryVideo; category <= CategoryBT; category++ {
+		res, err := f.categoryInfo(ctx, dir, category)
+		if err != nil {
+			return res, err
+		}
+		ret.CloudDiskResponseBase = res.CloudDiskResponseBase
+		for k, stat := range res.Info {
+			ret.Info[k] = stat
+		}
+	}
+
+	return ret, nil
+}
+
+// 获取目录下单个分类的文件数量和大小
+func (f *File) categoryInfo(ctx context.Context, dir string, category int) (CategoryInfoResponse, error) {
+	ret := CategoryInfoResponse{}
+
+	v := url.Values{}
+	v.Add("access_token", f.AccessToken)
+	v.Add("category", strconv.Itoa(category))
+	v.Add("parent_path", dir)
+	v.Add("recursion", "1")
+	query := v.Encode()
+
+	requestUrl := conf.OpenApiDomain + CategoryInfoUri + "?" + query
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
+	if err != nil {
+		log.Println("httpclient.Get failed, err:", err)
+		return ret, err
+	}
+
+	if resp.StatusCode != 200 {
+		return ret, errors.New(fmt.Sprintf("HttpStatusCode is not equal to 200, httpStatusCode[%d], respBody[%s]", resp.StatusCode, string(resp.Body)))
+	}
+
+	if err := json.Unmarshal(resp.Body, &ret); err != nil {
+		return ret, err
+	}
+
+	if ret.ErrorCode != 0 {//错误码不为0
+		return ret, errors.New(fmt.Sprintf("error_code:%d, error_msg:%s", ret.ErrorCode, ret.ErrorMsg))
+	}
+
+	return ret, nil
+}
+
+// 按分类获取文件列表，如获取目录下的所有视频或文档
+func (f *File) CategoryList(category int, opts CategoryListOptions) (CategoryListResponse, error) {
+	return f.CategoryListWithContext(context.Background(), category, opts)
+}
+
+// 按分类获取文件列表，如获取目录下的所有视频或文档，ctx取消时中断请求
+func (f *File) CategoryListWithContext(ctx context.Context, category int, opts CategoryListOptions) (CategoryListResponse, error) {
+	ret := CategoryListResponse{}
+
+	limit := opts.Limit
+	if limit <= 0 || limit > listAllMaxLimit {
+		limit = listAllMaxLimit
+	}
+
+	v := url.Values{}
+	v.Add("access_token", f.AccessToken)
+	v.Add("category", strconv.Itoa(category))
+	if opts.Dir != "" {
+		v.Add("parent_path", opts.Dir)
+	}
+	if opts.Recursion {
+		v.Add("recursion", "1")
+	}
+	if len(opts.Ext) > 0 {
+		v.Add("ext", strings.Join(opts.Ext, ","))
+	}
+	if opts.ShowDir {
+		v.Add("show_dir", "1")
+	}
+	if opts.Order != "" {
+		v.Add("order", opts.Order)
+	}
+	if opts.Desc {
+		v.Add("desc", "1")
+	}
+	v.Add("start", strconv.Itoa(opts.Start))
+	v.Add("limit", strconv.Itoa(limit))
+	query := v.Encode()
+
+	requestUrl := conf.OpenApiDomain + CategoryListUri + "&" + query
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
+	if err != nil {
+		log.Println("httpclient.Get failed, err:", err)
+		return ret, err
+	}
+
+	if resp.StatusCode != 200 {
+		return ret, errors.New(fmt.Sprintf("HttpStatusCode is not equal to 200, httpStatusCode[%d], respBody[%s]", resp.StatusCode, string(resp.Body)))
+	}
+
+	if err := json.Unmarshal(resp.Body, &ret); err != nil {
+		return ret, err
+	}
+
+	if ret.ErrorCode != 0 {//错误码不为0
+		return ret, errors.New(fmt.Sprintf("error_code:%d, error_msg:%s", ret.ErrorCode, ret.ErrorMsg))
+	}
+
+	return ret, nil
+}
+
+// 按分类遍历文件，自动翻页
+func (f *File) CategoryListIter(category int, opts CategoryListOptions) *ListIterator {
+	return f.CategoryListIterWithContext(context.Background(), category, opts)
+}
+
+// 按分类遍历文件，自动翻页，ctx取消时中断请求
+func (f *File) CategoryListIterWithContext(ctx context.Context, category int, opts CategoryListOptions) *ListIterator {
+	return newListIterator(func(start int) ([]ListItem, int, bool, error) {
+		pageOpts := opts
+		pageOpts.Start = start
+		res, err := f.CategoryListWithContext(ctx, category, pageOpts)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		return res.List, res.Cursor, res.HasMore == 1, nil
+	})
+}
+
+// 递归获取目录下的所有文件，自动翻页，文件数量很多时请使用ListAllIter
+func (f *File) ListAll(dir string, opts ListAllOptions) ([]ListItem, error) {
+	return f.ListAllWithContext(context.Background(), dir, opts)
+}
+
+// 递归获取目录下的所有文件，自动翻页，文件数量很多时请使用ListAllIter，ctx取消时中断请求
+func (f *File) ListAllWithContext(ctx context.Context, dir string, opts ListAllOptions) ([]ListItem, error) {
+	var list []ListItem
+
+	it := f.ListAllIterWithContext(ctx, dir, opts)
+	for it.Next() {
+		list = append(list, it.Item())
+	}
+
+	return list, it.Err()
+}
+
+// 递归遍历目录下的所有文件，每次只请求并缓存一页数据
+func (f *File) ListAllIter(dir string, opts ListAllOptions) *ListIterator {
+	return f.ListAllIterWithContext(context.Background(), dir, opts)
+}
+
+// 递归遍历目录下的所有文件，每次只请求并缓存一页数据，ctx取消时中断请求
+// 设置了本地过滤的条件时，请求次数与不设置时相同，只是不返回不符合条件的文件
+func (f *File) ListAllIterWithContext(ctx context.Context, dir string, opts ListAllOptions) *ListIterator {
+	it := newListIterator(func(start int) ([]ListItem, int, bool, error) {
+		res, err := f.listAllPage(ctx, dir, start, opts)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		return res.List, res.Cursor, res.HasMore == 1, nil
+	})
+	it.filter = func(item ListItem) bool {
+		if opts.DirOnly && item.IsDir != 1 {
+			return false
+		}
+		if opts.Category != 0 && item.Category != opts.Category {
+			return false
+		}
+		if opts.MtimeFrom != 0 && item.ServerMtime < opts.MtimeFrom {
+			return false
+		}
+		if opts.MtimeTo != 0 && item.ServerMtime > opts.MtimeTo {
+			return false
+		}
+		return true
+	}
+
+	return it
+}
+
+// 递归获取文件列表的一页数据，start为上一页返回的cursor
+func (f *File) listAllPage(ctx context.Context, dir string, start int, opts ListAllOptions) (ListAllResponse, error) {
+	ret := ListAllResponse{}
+
+	limit := opts.Limit
+	if limit <= 0 || limit > listAllMaxLimit {
+		limit = listAllMaxLimit
+	}
+
+	v := url.Values{}
+	v.Add("access_token", f.AccessToken)
+	v.Add("path", dir)
+	v.Add("recursion", "1")
+	v.Add("start", strconv.Itoa(start))
+	v.Add("limit", strconv.Itoa(limit))
+	if opts.Order != "" {
+		v.Add("order", opts.Order)
+	}
+	if opts.Desc {
+		v.Add("desc", "1")
+	}
+	if opts.Web {
+		v.Add("web", "1")
+	}
+	query := v.Encode()
+
+	requestUrl := conf.OpenApiDomain + ListAllUri + "&" + query
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
+	if err != nil {
+		log.Println("httpclient.Get failed, err:", err)
+		return ret, err
+	}
+
+	if resp.StatusCode != 200 {
+		return ret, errors.New(fmt.Sprintf("HttpStatusCode is not equal to 200, httpStatusCode[%d], respBody[%s]", resp.StatusCode, string(resp.Body)))
+	}
+
+	if err := json.Unmarshal(resp.Body, &ret); err != nil {
+		return ret, err
+	}
+
+	if ret.ErrorCode != 0 {//错误码不为0
+		return ret, errors.New(fmt.Sprintf("error_code:%d, error_msg:%s", ret.ErrorCode, ret.ErrorMsg))
+	}
+
+	return ret, nil
+}
+
+// 通过FsID获取文件信息，包括下载地址、缩略图和额外信息
 func (f *File) Metas(fsIDs []uint64) (MetasResponse, error) {
+	return f.MetasWithContext(context.Background(), fsIDs)
+}
+
+// 通过FsID获取文件信息，包括下载地址、缩略图和额外信息，ctx取消时中断请求
+func (f *File) MetasWithContext(ctx context.Context, fsIDs []uint64) (MetasResponse, error) {
+	return f.MetasWithOptionsWithContext(ctx, fsIDs, MetasOptions{DLink: true, Thumb: true, Extra: true})
+}
+
+// 通过FsID获取文件信息，fsIDs超过接口单次上限时分批并发请求，返回结果的顺序与fsIDs一致
+func (f *File) MetasWithOptions(fsIDs []uint64, opts MetasOptions) (MetasResponse, error) {
+	return f.MetasWithOptionsWithContext(context.Background(), fsIDs, opts)
+}
+
+// 通过FsID获取文件信息，fsIDs超过接口单次上限时分批并发请求，返回结果的顺序与fsIDs一致，ctx取消时中断请求
+func (f *File) MetasWithOptionsWithContext(ctx context.Context, fsIDs []uint64, opts MetasOptions) (MetasResponse, error) {
+	chunkNum := (len(fsIDs) + metasMaxFsIDs - 1) / metasMaxFsIDs
+	if chunkNum <= 1 {
+		ret, err := f.metas(ctx, fsIDs, opts)
+		if err != nil {
+			return ret, err
+		}
+		ret.List = sortMetasItems(fsIDs, ret.List)
+		return ret, nil
+	}
+
+	results := make([]MetasResponse, chunkNum)
+	errs := make([]error, chunkNum)
+	var wg sync.WaitGroup
+	sem := make(chan int, metasCoroutineNum) //限制并发数
+	for i := 0; i < chunkNum; i++ {
+		end := (i + 1) * metasMaxFsIDs
+		if end > len(fsIDs) {
+			end = len(fsIDs)
+		}
+		wg.Add(1)
+		sem <- 1
+		go func(i int, chunk []uint64) {
+			defer wg.Done()
+			results[i], errs[i] = f.metas(ctx, chunk, opts)
+			<-sem
+		}(i, fsIDs[i*metasMaxFsIDs:end])
+	}
+	wg.Wait()
+
+	ret := MetasResponse{}
+	var list []MetasItem
+	for i, res := range results {
+		if errs[i] != nil {
+			log.Println("fileClient.metas failed, err:", errs[i])
+			return res, errs[i]
+		}
+		if i == 0 {
+			ret = res
+		}
+		list = append(list, res.List...)
+	}
+	ret.List = sortMetasItems(fsIDs, list)
+
+	return ret, nil
+}
+
+// 按fsIDs的顺序排列文件信息，不存在的fsid会被忽略
+func sortMetasItems(fsIDs []uint64, list []MetasItem) []MetasItem {
+	itemMap := make(map[uint64]MetasItem, len(list))
+	for _, item := range list {
+		itemMap[item.FsID] = item
+	}
+
+	sorted := make([]MetasItem, 0, len(list))
+	for _, fsID := range fsIDs {
+		if item, ok := itemMap[fsID]; ok {
+			sorted = append(sorted, item)
+		}
+	}
+
+	return sorted
+}
+
+// 请求filemetas接口，fsIDs不能超过metasMaxFsIDs
+func (f *File) metas(ctx context.Context, fsIDs []uint64, opts MetasOptions) (MetasResponse, error) {
 	ret := MetasResponse{}
 
 	fsIDsByte, err := json.Marshal(fsIDs)
@@ -123,13 +706,22 @@ func (f *File) Metas(fsIDs []uint64) (MetasResponse, error) {
 	v := url.Values{}
 	v.Add("access_token", f.AccessToken)
 	v.Add("fsids", string(fsIDsByte))
-	v.Add("dlink", "1")
-	v.Add("thumb", "1")
-	v.Add("extra", "1")
+	if opts.DLink {
+		v.Add("dlink", "1")
+	}
+	if opts.Thumb {
+		v.Add("thumb", "1")
+	}
+	if opts.Extra {
+		v.Add("extra", "1")
+	}
+	if opts.NeedMedia {
+		v.Add("needmedia", "1")
+	}
 	query := v.Encode()
 
 	requestUrl := conf.OpenApiDomain + MetasUri + "&" + query
-	resp, err := httpclient.Get(requestUrl, map[string]string{})
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
 	if err != nil {
 		log.Println("httpclient.Get failed, err:", err)
 		return ret, err
@@ -157,6 +749,11 @@ func (f *File) Metas(fsIDs []uint64) (MetasResponse, error) {
 
 // 获取音视频在线播放地址，转码类型有M3U8_AUTO_480=>视频ts、M3U8_FLV_264_480=>视频flv、M3U8_MP3_128=>音频mp3、M3U8_HLS_MP3_128=>音频ts
 func (f *File) Streaming(path string, transcodingType string) (string, error) {
+	return f.StreamingWithContext(context.Background(), path, transcodingType)
+}
+
+// 获取音视频在线播放地址，转码类型有M3U8_AUTO_480=>视频ts、M3U8_FLV_264_480=>视频flv、M3U8_MP3_128=>音频mp3、M3U8_HLS_MP3_128=>音频ts，ctx取消时中断请求
+func (f *File) StreamingWithContext(ctx context.Context, path string, transcodingType string) (string, error) {
 	ret := ""
 
 	v := url.Values{}
@@ -166,7 +763,7 @@ func (f *File) Streaming(path string, transcodingType string) (string, error) {
 	query := v.Encode()
 
 	requestUrl := conf.OpenApiDomain + StreamingUri + "&" + query
-	resp, err := httpclient.Get(requestUrl, map[string]string{})
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
 	if err != nil {
 		log.Println("httpclient.Get failed, err:", err)
 		return ret, err
@@ -177,4 +774,98 @@ func (f *File) Streaming(path string, transcodingType string) (string, error) {
 	}
 
 	return string(resp.Body), nil
-}
\ No newline at end of file
+}
+
+// 创建目录，目录已存在时返回错误，ErrorCode为ErrnoFileExist
+func (f *File) Mkdir(dirPath string) (UploadResponse, error) {
+	return f.MkdirWithContext(context.Background(), dirPath)
+}
+
+// 创建目录，目录已存在时返回错误，ErrorCode为ErrnoFileExist，ctx取消时中断请求
+func (f *File) MkdirWithContext(ctx context.Context, dirPath string) (UploadResponse, error) {
+	defer f.ClearStatCache()
+	ret := UploadResponse{}
+
+	v := url.Values{}
+	v.Add("path", handleSpecialChar(dirPath))
+	v.Add("isdir", "1")
+	v.Add("rtype", "0")// 0 为不重命名，目录已存在时返回错误
+	body := v.Encode()
+
+	requestUrl := conf.OpenApiDomain + CreateUri + "&access_token=" + f.AccessToken
+	resp, err := httpclient.PostWithContext(ctx, requestUrl, map[string]string{}, body)
+	if err != nil {
+		log.Println("httpclient.Post failed, err:", err)
+		return ret, err
+	}
+
+	if resp.StatusCode != 200 {
+		return ret, errors.New(fmt.Sprintf("HttpStatusCode is not equal to 200, httpStatusCode[%d], respBody[%s]", resp.StatusCode, string(resp.Body)))
+	}
+
+	if err := json.Unmarshal(resp.Body, &ret); err != nil {
+		return ret, err
+	}
+
+	if ret.ErrorCode != 0 {//错误码不为0
+		return ret, errors.New(fmt.Sprintf("error_code:%d, error_msg:%s", ret.ErrorCode, ret.ErrorMsg))
+	}
+
+	return ret, nil
+}
+
+// 递归创建目录，类似mkdir -p，目录已存在时直接返回成功
+func (f *File) MkdirAll(dirPath string) error {
+	return f.MkdirAllWithContext(context.Background(), dirPath)
+}
+
+// 递归创建目录，类似mkdir -p，目录已存在时直接返回成功，ctx取消时中断请求
+func (f *File) MkdirAllWithContext(ctx context.Context, dirPath string) error {
+	dirPath = path.Clean("/" + dirPath)
+
+	// 从下往上找到已存在的目录，只创建缺失的部分，避免请求没有权限的上级目录（如/apps）
+	var missing []string
+	for p := dirPath; p != "/"; p = path.Dir(p) {
+		isDir, err := f.isDir(ctx, p)
+		if err != nil {
+			return err
+		}
+		if isDir {
+			break
+		}
+		missing = append(missing, p)
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		res, err := f.MkdirWithContext(ctx, missing[i])
+		if err == nil {
+			continue
+		}
+		if res.ErrorCode != ErrnoFileExist {
+			log.Println("Mkdir failed, err:", err)
+			return err
+		}
+		// 已存在，可能是并发创建的目录，也可能是同名文件
+		isDir, err := f.isDir(ctx, missing[i])
+		if err != nil {
+			return err
+		}
+		if !isDir {
+			return errors.New(fmt.Sprintf("path[%s] already exists and is not a directory", missing[i]))
+		}
+	}
+
+	return nil
+}
+
+// 判断路径是否为已存在的目录
+func (f *File) isDir(ctx context.Context, dirPath string) (bool, error) {
+	res, err := f.ListWithContext(ctx, dirPath, 0, 1)
+	if err == nil {
+		return true, nil
+	}
+	if res.ErrorCode == ErrnoFileNotExist {
+		return false, nil
+	}
+	return false, err
+}
@@ -1,7 +1,9 @@
 package file
 
 import (
+	"errors"
 	"github.com/jsyzchen/pan/conf"
+	"path"
 	"testing"
 )
 
@@ -14,6 +16,26 @@ func TestFile_List(t *testing.T) {
 	t.Logf("TestList res: %+v", res)
 }
 
+func TestFile_ListWithOptions(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	res, err := fileClient.ListWithOptions(conf.TestData.Dir, 0, 100, ListOptions{Order: "time", Desc: true, Web: true})
+	if err != nil {
+		t.Errorf("TestFile_ListWithOptions failed, err:%v", err)
+	}
+	t.Logf("TestFile_ListWithOptions res: %+v", res)
+}
+
+func TestFile_ListIter(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	it := fileClient.ListIter(conf.TestData.Dir, ListOptions{Folder: true, Limit: 10})
+	for it.Next() {
+		t.Logf("TestFile_ListIter item: %+v", it.Item())
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("TestFile_ListIter failed, err:%v", err)
+	}
+}
+
 func TestFile_Metas(t *testing.T) {
 	fileClient := NewFileClient(conf.TestData.AccessToken)
 	res, err := fileClient.Metas([]uint64{conf.TestData.FsID})
@@ -23,6 +45,19 @@ func TestFile_Metas(t *testing.T) {
 	t.Logf("TestMetas res: %+v", res)
 }
 
+func TestFile_MetasWithOptions(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	fsIDs := make([]uint64, 0, 250)
+	for i := 0; i < 250; i++ {
+		fsIDs = append(fsIDs, conf.TestData.FsID)
+	}
+	res, err := fileClient.MetasWithOptions(fsIDs, MetasOptions{})
+	if err != nil {
+		t.Errorf("TestFile_MetasWithOptions failed, err:%v", err)
+	}
+	t.Logf("TestFile_MetasWithOptions res: %+v", res)
+}
+
 func TestFile_Streaming(t *testing.T) {
 	fileClient := NewFileClient(conf.TestData.AccessToken)
 	res, err := fileClient.Streaming(conf.TestData.Path, conf.TestData.TranscodingType)
@@ -32,3 +67,100 @@ func TestFile_Streaming(t *testing.T) {
 	t.Logf("TestFile_Streaming res: %+v", res)
 }
 
+func TestFile_Mkdir(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	res, err := fileClient.Mkdir(conf.TestData.Dir + "/mkdir_test")
+	if err != nil {
+		t.Errorf("TestFile_Mkdir failed, err:%v", err)
+	}
+	t.Logf("TestFile_Mkdir res: %+v", res)
+}
+
+func TestFile_MkdirAll(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	err := fileClient.MkdirAll(conf.TestData.Dir + "/mkdir_all_test/a/b")
+	if err != nil {
+		t.Errorf("TestFile_MkdirAll failed, err:%v", err)
+	}
+}
+
+func TestFile_ListAll(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	res, err := fileClient.ListAll(conf.TestData.Dir, ListAllOptions{})
+	if err != nil {
+		t.Errorf("TestFile_ListAll failed, err:%v", err)
+	}
+	t.Logf("TestFile_ListAll res: %+v", res)
+}
+
+func TestFile_ListAllIter(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	it := fileClient.ListAllIter(conf.TestData.Dir, ListAllOptions{Limit: 100, DirOnly: true})
+	for it.Next() {
+		t.Logf("TestFile_ListAllIter item: %+v", it.Item())
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("TestFile_ListAllIter failed, err:%v", err)
+	}
+}
+
+func TestFile_Search(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	res, err := fileClient.Search(path.Base(conf.TestData.Path), conf.TestData.Dir, SearchOptions{Recursion: true})
+	if err != nil {
+		t.Errorf("TestFile_Search failed, err:%v", err)
+	}
+	t.Logf("TestFile_Search res: %+v", res)
+}
+
+func TestFile_SearchIter(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	it := fileClient.SearchIter(path.Base(conf.TestData.Path), conf.TestData.Dir, SearchOptions{Recursion: true, Num: 10})
+	for it.Next() {
+		t.Logf("TestFile_SearchIter item: %+v", it.Item())
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("TestFile_SearchIter failed, err:%v", err)
+	}
+}
+
+func TestFile_CategoryInfo(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	res, err := fileClient.CategoryInfo(conf.TestData.Dir)
+	if err != nil {
+		t.Errorf("TestFile_CategoryInfo failed, err:%v", err)
+	}
+	t.Logf("TestFile_CategoryInfo res: %+v", res)
+}
+
+func TestFile_CategoryList(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	res, err := fileClient.CategoryList(CategoryVideo, CategoryListOptions{Dir: conf.TestData.Dir, Recursion: true, Ext: []string{"mp4", "mkv"}})
+	if err != nil {
+		t.Errorf("TestFile_CategoryList failed, err:%v", err)
+	}
+	t.Logf("TestFile_CategoryList res: %+v", res)
+}
+
+func TestFile_Stat(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	res, err := fileClient.Stat(conf.TestData.Path)
+	if err != nil {
+		t.Errorf("TestFile_Stat failed, err:%v", err)
+	}
+	t.Logf("TestFile_Stat res: %+v", res)
+
+	if _, err := fileClient.Stat(conf.TestData.Path + ".not_exist"); !errors.Is(err, ErrFileNotExist) {
+		t.Errorf("TestFile_Stat not exist failed, err:%v", err)
+	}
+}
+
+func TestFile_MetasByPath(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	res, err := fileClient.MetasByPath([]string{conf.TestData.Path})
+	if err != nil {
+		t.Errorf("TestFile_MetasByPath failed, err:%v", err)
+	}
+	t.Logf("TestFile_MetasByPath res: %+v", res)
+}
+
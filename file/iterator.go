@@ -0,0 +1,68 @@
+package file
+
+// 获取一页文件列表，start为本页的起始位置，返回下一页的起始位置以及是否还有下一页
+type listPageFunc func(start int) (items []ListItem, next int, hasMore bool, err error)
+
+// 文件列表迭代器，自动翻页，每次只缓存一页数据
+//
+//	it := fileClient.ListAllIter("/apps/书梯", file.ListAllOptions{})
+//	for it.Next() {
+//		item := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type ListIterator struct {
+	fetch   listPageFunc
+	filter  func(item ListItem) bool
+	items   []ListItem
+	index   int
+	next    int
+	hasMore bool
+	item    ListItem
+	err     error
+}
+
+func newListIterator(fetch listPageFunc) *ListIterator {
+	return &ListIterator{
+		fetch:   fetch,
+		hasMore: true,
+	}
+}
+
+// 移动到下一个文件，没有更多文件或出错时返回false
+func (it *ListIterator) Next() bool {
+	for {
+		for it.index < len(it.items) {
+			item := it.items[it.index]
+			it.index++
+			if it.filter == nil || it.filter(item) {
+				it.item = item
+				return true
+			}
+		}
+
+		if !it.hasMore || it.err != nil {
+			return false
+		}
+
+		items, next, hasMore, err := it.fetch(it.next)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.items, it.index, it.next, it.hasMore = items, 0, next, hasMore
+		if len(items) == 0 {
+			it.hasMore = false
+		}
+	}
+}
+
+// 当前文件
+func (it *ListIterator) Item() ListItem {
+	return it.item
+}
+
+// 遍历过程中的错误
+func (it *ListIterator) Err() error {
+	return it.err
+}
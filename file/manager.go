@@ -0,0 +1,249 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jsyzchen/pan/conf"
+	"github.com/jsyzchen/pan/utils"
+	"github.com/jsyzchen/pan/utils/httpclient"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	FileManagerUri = "/rest/2.0/xpan/file?method=filemanager"
+	TaskQueryUri = "/share/taskquery"
+)
+
+// 文件操作类型
+const (
+	OperaCopy   = "copy"
+	OperaMove   = "move"
+	OperaRename = "rename"
+	OperaDelete = "delete"
+)
+
+// 文件操作的执行模式
+const (
+	ManagerSync     = 0 // 同步
+	ManagerAdaptive = 1 // 自适应，由服务端决定同步或异步
+	ManagerAsync    = 2 // 异步，返回taskid
+)
+
+// 目标文件已存在时的处理策略
+const (
+	OnDupFail      = "fail"      // 直接返回失败
+	OnDupNewCopy   = "newcopy"   // 重命名文件
+	OnDupOverwrite = "overwrite" // 覆盖
+	OnDupSkip      = "skip"      // 跳过
+)
+
+// 异步任务状态
+const (
+	TaskStatusPending = "pending"
+	TaskStatusRunning = "running"
+	TaskStatusSuccess = "success"
+	TaskStatusFailed  = "failed"
+)
+
+// WaitTask轮询和查询失败重试的间隔，从taskPollMinInterval开始每次翻倍，最大为taskPollMaxInterval
+const (
+	taskPollMinInterval = 500 * time.Millisecond
+	taskPollMaxInterval = 8 * time.Second
+)
+
+// 查询异步任务时可以重试的错误码，其他错误码（如任务不存在）重试也不会成功
+var taskQueryRetryableErrnos = map[int]bool{
+	31034: true, //命中接口频控
+}
+
+// 批量文件操作的单个条目，copy、move需要Dest，rename需要NewName
+type ManagerItem struct {
+	Path    string `json:"path"`
+	Dest    string `json:"dest,omitempty"`
+	NewName string `json:"newname,omitempty"`
+	OnDup   string `json:"ondup,omitempty"`
+}
+
+type TaskQueryResponse struct {
+	conf.CloudDiskResponseBase
+	Status    string `json:"status"`
+	TaskErrno int    `json:"task_errno"`
+	Progress  int    `json:"progress"`
+	List      []TaskResult `json:"list"`
+}
+
+// 异步任务中每个文件的执行结果，From为源路径，To为目标路径，删除操作To为空
+type TaskResult struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// 异步任务执行失败
+type TaskError struct {
+	TaskID    int
+	TaskErrno int
+	Response  TaskQueryResponse
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("task failed, taskid:%d, task_errno:%d", e.TaskID, e.TaskErrno)
+}
+
+// 复制文件，async取值ManagerSync、ManagerAdaptive、ManagerAsync，onDup为空时服务端默认为fail
+func (f *File) Copy(items []ManagerItem, async int, onDup string) (ManagerResponse, error) {
+	return f.CopyWithContext(context.Background(), items, async, onDup)
+}
+
+// 复制文件，async取值ManagerSync、ManagerAdaptive、ManagerAsync，onDup为空时服务端默认为fail，ctx取消时中断请求
+func (f *File) CopyWithContext(ctx context.Context, items []ManagerItem, async int, onDup string) (ManagerResponse, error) {
+	return f.manager(ctx, OperaCopy, items, async, onDup)
+}
+
+// 移动文件
+func (f *File) Move(items []ManagerItem, async int, onDup string) (ManagerResponse, error) {
+	return f.MoveWithContext(context.Background(), items, async, onDup)
+}
+
+// 移动文件，ctx取消时中断请求
+func (f *File) MoveWithContext(ctx context.Context, items []ManagerItem, async int, onDup string) (ManagerResponse, error) {
+	return f.manager(ctx, OperaMove, items, async, onDup)
+}
+
+// 重命名文件，只需要Path和NewName
+func (f *File) Rename(items []ManagerItem, async int, onDup string) (ManagerResponse, error) {
+	return f.RenameWithContext(context.Background(), items, async, onDup)
+}
+
+// 重命名文件，只需要Path和NewName，ctx取消时中断请求
+func (f *File) RenameWithContext(ctx context.Context, items []ManagerItem, async int, onDup string) (ManagerResponse, error) {
+	return f.manager(ctx, OperaRename, items, async, onDup)
+}
+
+// 删除文件
+func (f *File) Delete(paths []string, async int) (ManagerResponse, error) {
+	return f.DeleteWithContext(context.Background(), paths, async)
+}
+
+// 删除文件，ctx取消时中断请求
+func (f *File) DeleteWithContext(ctx context.Context, paths []string, async int) (ManagerResponse, error) {
+	return f.manager(ctx, OperaDelete, paths, async, "")
+}
+
+// 文件管理，每个条目的执行结果在ManagerResponse.Info中
+func (f *File) manager(ctx context.Context, opera string, fileList interface{}, async int, onDup string) (ManagerResponse, error) {
+	defer f.ClearStatCache()
+	ret := ManagerResponse{}
+
+	fileListByte, err := json.Marshal(fileList)
+	if err != nil {
+		return ret, err
+	}
+
+	v := url.Values{}
+	v.Add("async", strconv.Itoa(async))
+	v.Add("filelist", string(fileListByte))
+	if onDup != "" {
+		v.Add("ondup", onDup)
+	}
+	body := v.Encode()
+
+	requestUrl := conf.OpenApiDomain + FileManagerUri + "&access_token=" + f.AccessToken + "&opera=" + opera
+	resp, err := httpclient.PostWithContext(ctx, requestUrl, map[string]string{}, body)
+	if err != nil {
+		log.Println("httpclient.Post failed, err:", err)
+		return ret, err
+	}
+
+	if resp.StatusCode != 200 {
+		return ret, errors.New(fmt.Sprintf("HttpStatusCode is not equal to 200, httpStatusCode[%d], respBody[%s]", resp.StatusCode, string(resp.Body)))
+	}
+
+	if err := json.Unmarshal(resp.Body, &ret); err != nil {
+		return ret, err
+	}
+
+	if ret.ErrorCode != 0 {//错误码不为0，部分失败时可以通过Info查看每个条目的结果
+		return ret, errors.New(fmt.Sprintf("error_code:%d, error_msg:%s", ret.ErrorCode, ret.ErrorMsg))
+	}
+
+	return ret, nil
+}
+
+// 查询异步任务的执行状态
+func (f *File) TaskQuery(taskID int) (TaskQueryResponse, error) {
+	return f.TaskQueryWithContext(context.Background(), taskID)
+}
+
+// 查询异步任务的执行状态，ctx取消时中断请求
+func (f *File) TaskQueryWithContext(ctx context.Context, taskID int) (TaskQueryResponse, error) {
+	ret := TaskQueryResponse{}
+
+	v := url.Values{}
+	v.Add("access_token", f.AccessToken)
+	v.Add("taskid", strconv.Itoa(taskID))
+	query := v.Encode()
+
+	requestUrl := conf.OpenApiDomain + TaskQueryUri + "?" + query
+	resp, err := httpclient.GetWithContext(ctx, requestUrl, map[string]string{})
+	if err != nil {
+		log.Println("httpclient.Get failed, err:", err)
+		return ret, err
+	}
+
+	if resp.StatusCode != 200 {
+		return ret, errors.New(fmt.Sprintf("HttpStatusCode is not equal to 200, httpStatusCode[%d], respBody[%s]", resp.StatusCode, string(resp.Body)))
+	}
+
+	if err := json.Unmarshal(resp.Body, &ret); err != nil {
+		return ret, err
+	}
+
+	if ret.ErrorCode != 0 {//错误码不为0
+		return ret, errors.New(fmt.Sprintf("error_code:%d, error_msg:%s", ret.ErrorCode, ret.ErrorMsg))
+	}
+
+	return ret, nil
+}
+
+// 等待异步任务执行结束，任务失败时返回*TaskError，超时通过ctx控制
+// 查询时的网络错误、非200状态码或taskQueryRetryableErrnos中的错误码按轮询间隔重试直到ctx结束，其他错误码（如任务不存在）立即返回
+// 状态为pending、running时继续轮询，未知的状态返回error
+func (f *File) WaitTask(ctx context.Context, taskID int) (TaskQueryResponse, error) {
+	interval := taskPollMinInterval
+	for {
+		ret, err := f.TaskQueryWithContext(ctx, taskID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ret, ctx.Err()
+			}
+			if ret.ErrorCode != 0 && !taskQueryRetryableErrnos[ret.ErrorCode] {//服务端明确返回的错误码，重试也不会成功
+				return ret, err
+			}
+			log.Printf("task query failed, retry after %v, taskid[%d] err[%v]", interval, taskID, err)
+		} else {
+			switch ret.Status {
+			case TaskStatusSuccess:
+				return ret, nil
+			case TaskStatusFailed:
+				return ret, &TaskError{TaskID: taskID, TaskErrno: ret.TaskErrno, Response: ret}
+			case TaskStatusPending, TaskStatusRunning://继续轮询
+			default:
+				return ret, errors.New(fmt.Sprintf("unknown task status[%s], taskid:%d", ret.Status, taskID))
+			}
+		}
+
+		if err := utils.SleepContext(ctx, interval); err != nil {
+			return ret, err
+		}
+
+		interval *= 2
+		if interval > taskPollMaxInterval {
+			interval = taskPollMaxInterval
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package file
+
+import (
+	"context"
+	"github.com/jsyzchen/pan/conf"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestFile_Copy(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	items := []ManagerItem{{Path: conf.TestData.Path, Dest: conf.TestData.Dir, NewName: "copy_" + path.Base(conf.TestData.Path)}}
+	res, err := fileClient.Copy(items, ManagerSync, OnDupNewCopy)
+	if err != nil {
+		t.Errorf("TestFile_Copy failed, err:%v", err)
+	}
+	t.Logf("TestFile_Copy res: %+v", res)
+}
+
+func TestFile_Move(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	items := []ManagerItem{{Path: path.Join(conf.TestData.Dir, "copy_"+path.Base(conf.TestData.Path)), Dest: conf.TestData.Dir, NewName: "move_" + path.Base(conf.TestData.Path)}}
+	res, err := fileClient.Move(items, ManagerAdaptive, OnDupOverwrite)
+	if err != nil {
+		t.Errorf("TestFile_Move failed, err:%v", err)
+	}
+	t.Logf("TestFile_Move res: %+v", res)
+}
+
+func TestFile_Rename(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	items := []ManagerItem{{Path: path.Join(conf.TestData.Dir, "move_"+path.Base(conf.TestData.Path)), NewName: "rename_" + path.Base(conf.TestData.Path)}}
+	res, err := fileClient.Rename(items, ManagerSync, OnDupFail)
+	if err != nil {
+		t.Errorf("TestFile_Rename failed, err:%v", err)
+	}
+	t.Logf("TestFile_Rename res: %+v", res)
+}
+
+func TestFile_Delete(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	res, err := fileClient.Delete([]string{path.Join(conf.TestData.Dir, "rename_"+path.Base(conf.TestData.Path))}, ManagerSync)
+	if err != nil {
+		t.Errorf("TestFile_Delete failed, err:%v", err)
+	}
+	t.Logf("TestFile_Delete res: %+v", res)
+}
+
+func TestFile_WaitTask(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	items := []ManagerItem{{Path: conf.TestData.Path, Dest: conf.TestData.Dir, NewName: "async_" + path.Base(conf.TestData.Path)}}
+	res, err := fileClient.Copy(items, ManagerAsync, OnDupNewCopy)
+	if err != nil {
+		t.Fatalf("TestFile_WaitTask Copy failed, err:%v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	taskRes, err := fileClient.WaitTask(ctx, res.TaskID)
+	if err != nil {
+		t.Errorf("TestFile_WaitTask failed, err:%v", err)
+	}
+	t.Logf("TestFile_WaitTask res: %+v", taskRes)
+}
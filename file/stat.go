@@ -0,0 +1,203 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"time"
+)
+
+// 文件或目录不存在，可以通过errors.Is判断
+var ErrFileNotExist = errors.New("file don't exist")
+
+// 统一List和Metas返回的文件信息
+type FileInfo struct {
+	FsID        uint64
+	Path        string
+	Name        string
+	Size        int64
+	IsDir       bool
+	Category    int
+	Md5         string
+	DLink       string // 只有MetasByPath会返回
+	Thumbs      map[string]string
+	ServerCtime int
+	ServerMtime int
+}
+
+// 文件在网盘的修改时间
+func (fi FileInfo) ModTime() time.Time {
+	return time.Unix(int64(fi.ServerMtime), 0)
+}
+
+// 转换为FileInfo
+func (item ListItem) FileInfo() FileInfo {
+	return FileInfo{
+		FsID:        item.FsID,
+		Path:        item.Path,
+		Name:        item.ServerFileName,
+		Size:        int64(item.Size),
+		IsDir:       item.IsDir == 1,
+		Category:    item.Category,
+		Md5:         item.Md5,
+		Thumbs:      item.Thumbs,
+		ServerCtime: item.ServerCtime,
+		ServerMtime: item.ServerMtime,
+	}
+}
+
+// Stat缓存的目录列表的有效期和最多缓存的目录数
+const (
+	statCacheTTL = 10 * time.Second
+	statCacheMaxDirs = 256
+)
+
+// Stat缓存的目录列表
+type statCacheEntry struct {
+	entries map[string]ListItem
+	expireAt time.Time
+}
+
+// 通过文件路径获取文件信息，文件不存在时返回ErrFileNotExist
+// 通过列出上级目录来查找文件，上级目录的列表会缓存10秒，缓存中没有的文件会重新列出一次，可以通过ClearStatCache清除
+func (f *File) Stat(filePath string) (FileInfo, error) {
+	return f.StatWithContext(context.Background(), filePath)
+}
+
+// 通过文件路径获取文件信息，文件不存在时返回ErrFileNotExist，ctx取消时中断请求
+// 通过列出上级目录来查找文件，上级目录的列表会缓存10秒，缓存中没有的文件会重新列出一次，可以通过ClearStatCache清除
+func (f *File) StatWithContext(ctx context.Context, filePath string) (FileInfo, error) {
+	filePath = path.Clean("/" + filePath)
+	if filePath == "/" {
+		return FileInfo{Path: "/", Name: "/", IsDir: true}, nil
+	}
+
+	dir := path.Dir(filePath)
+	entries, cached, err := f.dirEntries(ctx, dir)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	item, ok := entries[path.Base(filePath)]
+	if !ok && cached {//缓存中没有时重新列出一次，文件可能是之后新建的
+		f.deleteStatCache(dir)
+		if entries, _, err = f.dirEntries(ctx, dir); err != nil {
+			return FileInfo{}, err
+		}
+		item, ok = entries[path.Base(filePath)]
+	}
+	if !ok {
+		return FileInfo{}, fmt.Errorf("stat %s: %w", filePath, ErrFileNotExist)
+	}
+
+	return item.FileInfo(), nil
+}
+
+// 通过文件路径批量获取文件信息，包括下载地址，返回结果的顺序与paths一致
+func (f *File) MetasByPath(paths []string) ([]FileInfo, error) {
+	return f.MetasByPathWithContext(context.Background(), paths)
+}
+
+// 通过文件路径批量获取文件信息，包括下载地址，返回结果的顺序与paths一致，ctx取消时中断请求
+func (f *File) MetasByPathWithContext(ctx context.Context, paths []string) ([]FileInfo, error) {
+	infos := make([]FileInfo, len(paths))
+	fsIDs := make([]uint64, 0, len(paths))
+	for i, p := range paths {
+		info, err := f.StatWithContext(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		infos[i] = info
+		if info.FsID != 0 {
+			fsIDs = append(fsIDs, info.FsID)
+		}
+	}
+
+	if len(fsIDs) == 0 {
+		return infos, nil
+	}
+
+	metas, err := f.MetasWithOptionsWithContext(ctx, fsIDs, MetasOptions{DLink: true})
+	if err != nil {
+		log.Println("fileClient.Metas failed, err:", err)
+		return nil, err
+	}
+
+	dLinks := make(map[uint64]string, len(metas.List))
+	for _, meta := range metas.List {
+		dLinks[meta.FsID] = meta.DLink
+	}
+	for i := range infos {
+		infos[i].DLink = dLinks[infos[i].FsID]
+	}
+
+	return infos, nil
+}
+
+// 清除Stat缓存的目录列表，通过其他方式修改了网盘文件后需要调用
+func (f *File) ClearStatCache() {
+	f.statMu.Lock()
+	f.statCache = nil
+	f.statMu.Unlock()
+}
+
+func (f *File) deleteStatCache(dir string) {
+	f.statMu.Lock()
+	delete(f.statCache, dir)
+	f.statMu.Unlock()
+}
+
+// 获取目录下的文件，key为文件名，cached表示是否来自缓存
+func (f *File) dirEntries(ctx context.Context, dir string) (map[string]ListItem, bool, error) {
+	f.statMu.Lock()
+	cache, ok := f.statCache[dir]
+	f.statMu.Unlock()
+	if ok && time.Now().Before(cache.expireAt) {
+		return cache.entries, true, nil
+	}
+
+	entries := make(map[string]ListItem)
+	it := f.ListIterWithContext(ctx, dir, ListOptions{})
+	for it.Next() {
+		item := it.Item()
+		entries[item.ServerFileName] = item
+	}
+	if err := it.Err(); err != nil {
+		if isDir, dirErr := f.isDir(ctx, dir); dirErr == nil && !isDir {
+			return nil, false, fmt.Errorf("stat %s: %w", dir, ErrFileNotExist)
+		}
+		return nil, false, err
+	}
+
+	f.statMu.Lock()
+	defer f.statMu.Unlock()
+	if f.statCache == nil {
+		f.statCache = make(map[string]*statCacheEntry)
+	}
+	f.evictStatCache()
+	f.statCache[dir] = &statCacheEntry{entries: entries, expireAt: time.Now().Add(statCacheTTL)}
+
+	return entries, false, nil
+}
+
+// 删除过期的目录列表，仍然超过statCacheMaxDirs时删除最早过期的，调用前需要持有statMu
+func (f *File) evictStatCache() {
+	now := time.Now()
+	for dir, cache := range f.statCache {
+		if !now.Before(cache.expireAt) {
+			delete(f.statCache, dir)
+		}
+	}
+
+	for len(f.statCache) >= statCacheMaxDirs {
+		oldest := ""
+		for dir, cache := range f.statCache {
+			if oldest == "" || cache.expireAt.Before(f.statCache[oldest].expireAt) {
+				oldest = dir
+			}
+		}
+		delete(f.statCache, oldest)
+	}
+}
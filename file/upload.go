@@ -1,17 +1,17 @@
 package file
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/bitly/go-simplejson"
 	"github.com/jsyzchen/pan/account"
 	"github.com/jsyzchen/pan/conf"
+	"github.com/jsyzchen/pan/utils"
 	fileUtil "github.com/jsyzchen/pan/utils/file"
 	"github.com/jsyzchen/pan/utils/httpclient"
-	"github.com/syyongx/php2go"
+	"github.com/jsyzchen/pan/utils/ratelimit"
 	"io"
 	"log"
 	"math"
@@ -19,11 +19,13 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type UploadResponse struct {
 	conf.CloudDiskResponseBase
-	Path      string `json:"path"`
+	Path      string `json:"path"` //网盘中的最终路径，发生重命名时为重命名后的路径
 	Size      int    `json:"size"`
 	Ctime     int    `json:"ctime"`
 	Mtime     int    `json:"mtime"`
@@ -48,15 +50,36 @@ type SuperFile2UploadResponse struct {
 	PartSeq string `json:"partseq"`//pcsapi PHP版本返回的是int类型，Go版本返回的是string类型
 }
 
+// 要上传文件的信息，读取一次文件计算得到
 type LocalFileInfo struct {
 	Md5 string
 	Size int64
+	SliceMd5 string //文件前256KB的md5
+	SliceSize int64 //block_list的分片大小
+	BlockList []string
+	Mtime int64 //计算时本地文件的修改时间（UnixNano），与当前的修改时间不一致时重新计算
 }
 
 type Uploader struct {
 	AccessToken string
 	Path string
 	LocalFilePath string
+	OnConflict int //网盘已存在同名文件时的处理策略，默认为OnConflictRename
+	StateStore UploadStateStore //不为空时支持断点续传
+	MaxRetries int //单个分片上传失败后的最大重试次数，0为默认值3，小于0不重试
+	SpoolDir string //不能随机读取的reader缓存到该目录，默认为os.TempDir()
+	MaxSpoolSize int64 //不能随机读取的reader最大缓存字节数，NewReaderUploader默认为4G，0为不限制
+	FileInfo *LocalFileInfo //要上传文件的md5等信息，为空时在上传时计算，可以提前计算后设置
+	OnProgress fileUtil.ProgressListener //上传进度回调，已上传字节数在发送分片时实时更新，失败的分片回退
+	Limiter *ratelimit.Limiter //上传限速，限制的是所有分片的总速度，多个Uploader可以共享同一个Limiter
+	reader io.Reader
+	readerSize int64
+	readerAt io.ReaderAt //可以随机读取的上传内容，reader准备好后才有值
+	spoolFile *os.File
+	disableRapidUpload bool
+	vipType *int //用户身份，第一次获取后缓存
+	vipTypeFailed bool //获取用户身份失败，vipType为按普通用户处理的默认值，下次Upload时重新获取
+	tracker *fileUtil.ProgressTracker //分片发送的字节数实时计入上传进度
 }
 
 const (
@@ -65,6 +88,30 @@ const (
 	Superfile2UploadUri = "/rest/2.0/pcs/superfile2?method=upload"
 )
 
+// 上传时网盘已存在同名文件的处理策略
+const (
+	OnConflictRename = iota //重命名，默认
+	OnConflictFail //返回ConflictError
+	OnConflictOverwriteIfDiff //文件内容不同时覆盖，相同时跳过上传，返回网盘上已有文件的信息
+	OnConflictOverwrite //覆盖
+)
+
+// 网盘已存在同名文件，OnConflict为OnConflictFail时返回
+type ConflictError struct {
+	Path string
+	ErrorCode int
+	ErrorMsg string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("path[%s] already exists, error_code:%d, error_msg:%s", e.Path, e.ErrorCode, e.ErrorMsg)
+}
+
+// 同名文件已存在的错误码
+func isConflict(errorCode int) bool {
+	return errorCode == ErrnoFileExist || errorCode == 31061
+}
+
 func NewUploader(accessToken, path, localFilePath string) *Uploader {
 	return &Uploader{
 		AccessToken: accessToken,
@@ -73,110 +120,260 @@ func NewUploader(accessToken, path, localFilePath string) *Uploader {
 	}
 }
 
+// 使用File的AccessToken和Limiter创建Uploader
+func (f *File) NewUploader(path, localFilePath string) *Uploader {
+	u := NewUploader(f.AccessToken, path, localFilePath)
+	u.Limiter = f.Limiter
+	return u
+}
+
 // 上传文件到网盘，包括预创建、分片上传、创建3个步骤
+// 设置了StateStore时支持断点续传，进程重启后只上传缺失的分片
 func (u *Uploader) Upload() (UploadResponse, error) {
-	var ret UploadResponse
+	return u.UploadWithContext(context.Background())
+}
 
-	//1. file precreate
-	preCreateRes, err := u.PreCreate()
-	if err != nil {
-		log.Println("PreCreate failed, err:", err)
-		ret.ErrorCode = preCreateRes.ErrorCode
-		ret.ErrorMsg = preCreateRes.ErrorMsg
-		ret.RequestID = preCreateRes.RequestID
-		return ret, err
+// 上传文件到网盘，包括预创建、分片上传、创建3个步骤，ctx取消时中断请求
+// 设置了StateStore时支持断点续传，进程重启后只上传缺失的分片
+func (u *Uploader) UploadWithContext(ctx context.Context) (UploadResponse, error) {
+	u.resetFailedVipType()
+	if u.reader != nil {
+		defer u.cleanupReader()
+		if err := u.prepareReader(ctx); err != nil {
+			log.Println("prepareReader failed, err:", err)
+			return UploadResponse{}, err
+		}
 	}
 
-	if preCreateRes.ReturnType == 2 {//云端已存在相同文件，直接上传成功，无需请求后面的分片上传和创建文件接口
-		preCreateRes.Info.ErrorCode = preCreateRes.ErrorCode
-		preCreateRes.Info.ErrorMsg = preCreateRes.ErrorMsg
-		preCreateRes.Info.RequestID = preCreateRes.RequestID
-		return preCreateRes.Info, nil
+	ret, err := u.upload(ctx)
+	if err == errUploadIDExpired {//uploadid已失效，清除上传进度后重新上传
+		log.Println("uploadid expired, restart upload")
+		if err := u.deleteState(); err != nil {
+			log.Println("deleteState failed, err:", err)
+			return ret, err
+		}
+		return u.upload(ctx)
 	}
 
-	uploadID := preCreateRes.UploadID
+	return ret, err
+}
+
+func (u *Uploader) upload(ctx context.Context) (UploadResponse, error) {
+	var ret UploadResponse
 
-	//2. superfile2 upload
-	fileInfo, err := u.getFileInfo()
+	fileInfo, err := u.getFileInfo(ctx)
 	if err != nil {
 		log.Println("getFileInfo failed, err:", err)
 		return ret, err
 	}
 	fileSize := fileInfo.Size
 
-	sliceSize, err := u.getSliceSize(fileSize)
+	if u.OnConflict == OnConflictOverwriteIfDiff {
+		remote, same, err := u.sameRemoteFile(ctx, fileInfo)
+		if err != nil {
+			log.Println("sameRemoteFile failed, err:", err)
+			return ret, err
+		}
+		if same {//网盘上的文件内容相同，不需要上传
+			return remote, nil
+		}
+	}
+
+	state, err := u.loadState(fileInfo)
 	if err != nil {
-		log.Println("getSliceSize failed, err:", err)
+		log.Println("loadState failed, err:", err)
 		return ret, err
 	}
 
-	sliceNum := int(math.Ceil(float64(fileSize) / float64(sliceSize)))
+	if state == nil {
+		//1. file precreate
+		preCreateRes, err := u.PreCreateWithContext(ctx)
+		if err != nil {
+			log.Println("PreCreate failed, err:", err)
+			ret.ErrorCode = preCreateRes.ErrorCode
+			ret.ErrorMsg = preCreateRes.ErrorMsg
+			ret.RequestID = preCreateRes.RequestID
+			return ret, err
+		}
+
+		if preCreateRes.ReturnType == 2 {//云端已存在相同文件，直接上传成功，无需请求后面的分片上传和创建文件接口
+			preCreateRes.Info.ErrorCode = preCreateRes.ErrorCode
+			preCreateRes.Info.ErrorMsg = preCreateRes.ErrorMsg
+			preCreateRes.Info.RequestID = preCreateRes.RequestID
+			return preCreateRes.Info, nil
+		}
+
+		sliceSize := fileInfo.SliceSize//与block_list的分片大小保持一致
+
+		state = &UploadState{
+			UploadID: preCreateRes.UploadID,
+			Path: u.Path,
+			LocalFilePath: u.LocalFilePath,
+			Size: fileSize,
+			Md5: fileInfo.Md5,
+			SliceSize: sliceSize,
+			Parts: make(map[int]string),
+			CreatedAt: time.Now().Unix(),
+		}
+		if err := u.saveState(state); err != nil {
+			log.Println("saveState failed, err:", err)
+			return ret, err
+		}
+	}
+
+	uploadID := state.UploadID
+	sliceSize := state.SliceSize
+	sliceNum := 1 //空文件也需要上传一个空的分片
+	if fileSize > 0 {
+		sliceNum = int(math.Ceil(float64(fileSize) / float64(sliceSize)))
+	}
+
+	//2. superfile2 upload，跳过已上传的分片
+	uploadedParts := make(map[int]bool, len(state.Parts))
+	for partSeq := range state.Parts {
+		uploadedParts[partSeq] = true
+	}
 
-	//TODO 断点续传
-	file, err := os.Open(u.LocalFilePath)
+	partSizes := make([]int64, sliceNum)
+	for i := range partSizes {
+		partSizes[i] = sliceSize
+		if i == sliceNum-1 {
+			partSizes[i] = fileSize - int64(i)*sliceSize
+		}
+	}
+	tracker := fileUtil.NewProgressTracker(u.OnProgress, partSizes)
+	for partSeq := range uploadedParts {
+		tracker.SetPartDone(partSeq)
+	}
+	u.tracker = tracker
+	defer func() { u.tracker = nil }()
+
+	file, closeSource, err := u.openSource()
 	if err != nil {
 		return ret, err
 	}
-	defer file.Close()
-	uploadRespChan := make(chan SuperFile2UploadResponse, sliceNum)
+	defer closeSource()
+	// 任一分片最终失败后取消其他正在上传的分片，并且不再读取和上传后面的分片
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stateMu sync.Mutex
+	var failedResp SuperFile2UploadResponse
+	var failedErr error
+	var wg sync.WaitGroup
 	sem := make(chan int, 10) //限制并发数，以防大文件上传导致占用服务器大量内存
 	for i := 0; i < sliceNum; i++ {
+		if uploadedParts[i] {
+			continue
+		}
+
+		select {
+		case sem <- 1: //当通道已满的时候将被阻塞
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
 		buffer := make([]byte, sliceSize)
-		n, err := file.Read(buffer[:])
+		n, err := file.ReadAt(buffer, int64(i)*sliceSize)
 		if err != nil && err != io.EOF {
-			log.Println("file.Read failed, err:", err)
+			log.Println("file.ReadAt failed, err:", err)
+			<-sem
+			cancel()
+			wg.Wait()
 			return ret, err
 		}
-		if n == 0 { //文件已读取结束
+		if n == 0 && fileSize > 0 { //文件已读取结束
+			<-sem
 			break
 		}
 
-		sem <- 1 //当通道已满的时候将被阻塞
+		wg.Add(1)
 		go func(partSeq int, partByte []byte) {
-			uploadResp, err := u.SuperFile2Upload(uploadID, partSeq, partByte)
-			uploadRespChan <- uploadResp
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			tracker.SetPartState(partSeq, fileUtil.PartRunning)
+			uploadResp, err := u.superFile2UploadWithRetry(ctx, uploadID, partSeq, partByte)
 			if err != nil {
-				log.Printf("SuperFile2UploadFailed, partseq[%d] err[%v]", partSeq, err)
+				tracker.SetPartState(partSeq, fileUtil.PartFailed)
+			} else {
+				tracker.SetPartState(partSeq, fileUtil.PartDone)
+			}
+			stateMu.Lock()
+			defer stateMu.Unlock()
+			if err != nil {
+				if failedErr == nil && ctx.Err() == nil {//只记录第一个失败的分片
+					log.Printf("SuperFile2UploadFailed, partseq[%d] err[%v]", partSeq, err)
+					failedResp, failedErr = uploadResp, err
+					cancel()
+				}
+				return
+			}
+			//记录上传进度
+			state.Parts[partSeq] = uploadResp.Md5
+			if err := u.saveState(state); err != nil {
+				log.Println("saveState failed, err:", err)
 			}
-			<-sem
 		}(i, buffer[0:n])
 	}
+	wg.Wait()
 
-	blockList := make([]string, sliceNum)
-	for i := 0; i < sliceNum; i++ {
-		uploadResp := <-uploadRespChan
-		if uploadResp.ErrorCode != 0 {//有部分文件上传失败
-			log.Print("superfile2 upload part failed")
-			ret.ErrorCode = uploadResp.ErrorCode
-			ret.ErrorMsg = uploadResp.ErrorMsg
-			ret.RequestID = uploadResp.RequestID
-			return ret, errors.New("superfile2 upload part failed")
-		}
-
-		partSeq, err := strconv.Atoi(uploadResp.PartSeq)
-		if err != nil {
-			log.Println("strconv.Atoi failed, err:", err)
-			return ret, err
+	if failedErr != nil {//有部分文件上传失败
+		log.Print("superfile2 upload part failed")
+		if isUploadIDExpired(failedResp.ErrorCode) {
+			return ret, errUploadIDExpired
 		}
+		ret.ErrorCode = failedResp.ErrorCode
+		ret.ErrorMsg = failedResp.ErrorMsg
+		ret.RequestID = failedResp.RequestID
+		return ret, failedErr
+	}
+	if ctx.Err() != nil {//调用方取消，已上传的分片保存在上传进度中
+		return ret, ctx.Err()
+	}
 
-		blockList[partSeq] = uploadResp.Md5
+	blockList := make([]string, sliceNum)
+	stateMu.Lock()
+	for i := 0; i < sliceNum; i++ {
+		blockList[i] = state.Parts[i]
 	}
+	stateMu.Unlock()
 
 	//3. file create
-	superFile2CommitRes, err := u.Create(uploadID, blockList)
+	superFile2CommitRes, err := u.CreateWithContext(ctx, uploadID, blockList)
 	if err != nil {
 		log.Println("SuperFile2Commit failed, err:", err)
+		if isUploadIDExpired(superFile2CommitRes.ErrorCode) {
+			return superFile2CommitRes, errUploadIDExpired
+		}
 		return superFile2CommitRes, err
 	}
 
+	if err := u.deleteState(); err != nil {
+		log.Println("deleteState failed, err:", err)
+	}
+
 	return superFile2CommitRes, err
 }
 
+// 支持断点续传的上传，上传进度保存在store中，进程重启后使用相同的参数再次调用Upload即可继续上传
+func NewResumableUploader(accessToken, path, localFilePath string, store UploadStateStore) *Uploader {
+	u := NewUploader(accessToken, path, localFilePath)
+	u.StateStore = store
+	return u
+}
+
 // preCreate
 func (u *Uploader) PreCreate() (PreCreateResponse, error) {
+	return u.PreCreateWithContext(context.Background())
+}
+
+// preCreate，ctx取消时中断请求
+func (u *Uploader) PreCreateWithContext(ctx context.Context) (PreCreateResponse, error) {
 	ret := PreCreateResponse{}
 
-	fileInfo, err := u.getFileInfo()
+	fileInfo, err := u.getFileInfo(ctx)
 	if err != nil {
 		log.Println("getFileInfo failed, err:", err)
 		return ret, err
@@ -184,13 +381,13 @@ func (u *Uploader) PreCreate() (PreCreateResponse, error) {
 	fileSize := fileInfo.Size
 	fileMd5 := fileInfo.Md5
 
-	sliceMd5, err := u.getSliceMd5()
+	sliceMd5, err := u.getSliceMd5(ctx)
 	if err != nil {
 		log.Println("getSliceMd5 failed, err:", err)
 		return ret, err
 	}
 
-	blockList, err := u.getBlockList()
+	blockList, err := u.getBlockList(ctx)
 	if err != nil {
 		log.Println("getBlockList failed, err:", err)
 		return ret, err
@@ -207,15 +404,17 @@ func (u *Uploader) PreCreate() (PreCreateResponse, error) {
 	v.Add("size", strconv.FormatInt(fileSize, 10))
 	v.Add("isdir", "0")
 	v.Add("autoinit", "1")// 固定值1
-	v.Add("rtype", "1")// 1 为只要path冲突即重命名
+	v.Add("rtype", strconv.Itoa(u.rtype()))
 	v.Add("block_list", blockListStr)
-	v.Add("content-md5", fileMd5)
-	v.Add("slice-md5", sliceMd5)
+	if !u.disableRapidUpload {
+		v.Add("content-md5", fileMd5)
+		v.Add("slice-md5", sliceMd5)
+	}
 	body := v.Encode()
 
 	requestUrl := conf.OpenApiDomain + PreCreateUri + "&access_token=" + u.AccessToken
 	headers := make(map[string]string)
-	resp, err := httpclient.Post(requestUrl, headers, body)
+	resp, err := httpclient.PostWithContext(ctx, requestUrl, headers, body)
 	if err != nil {
 		log.Println("httpclient.Post failed, err:", err)
 		return ret, err
@@ -239,6 +438,9 @@ func (u *Uploader) PreCreate() (PreCreateResponse, error) {
 	}
 
 	if ret.ErrorCode != 0 {//错误码不为0
+		if isConflict(ret.ErrorCode) {
+			return ret, &ConflictError{Path: u.Path, ErrorCode: ret.ErrorCode, ErrorMsg: ret.ErrorMsg}
+		}
 		return ret, errors.New(fmt.Sprintf("error_code:%d, error_msg:%s", ret.ErrorCode, ret.ErrorMsg))
 	}
 
@@ -247,10 +449,18 @@ func (u *Uploader) PreCreate() (PreCreateResponse, error) {
 
 //superfile2 upload
 func (u *Uploader) SuperFile2Upload(uploadID string, partSeq int, partByte []byte) (SuperFile2UploadResponse, error) {
+	return u.SuperFile2UploadWithContext(context.Background(), uploadID, partSeq, partByte)
+}
+
+//superfile2 upload，ctx取消时中断请求
+func (u *Uploader) SuperFile2UploadWithContext(ctx context.Context, uploadID string, partSeq int, partByte []byte) (SuperFile2UploadResponse, error) {
 	ret := SuperFile2UploadResponse{}
 
 	path := u.Path
 	localFilePath := u.LocalFilePath
+	if localFilePath == "" {//通过reader上传时使用网盘文件名
+		localFilePath = path
+	}
 
 	// path urlencode
 	v := url.Values{}
@@ -264,7 +474,10 @@ func (u *Uploader) SuperFile2Upload(uploadID string, partSeq int, partByte []byt
 	uploadUrl := conf.PcsDataDomain + Superfile2UploadUri + "&" + queryParams
 
 	fileUploader := fileUtil.NewFileUploader(uploadUrl, localFilePath)
-	resp, err := fileUploader.UploadByByte(partByte)
+	fileUploader.Limiter = u.Limiter
+	fileUploader.Tracker = u.tracker
+	fileUploader.PartIndex = partSeq
+	resp, err := fileUploader.UploadByByteWithContext(ctx, partByte)
 	if err != nil {
 		log.Print("fileUploader.UploadByByte failed")
 		return ret, err
@@ -284,10 +497,15 @@ func (u *Uploader) SuperFile2Upload(uploadID string, partSeq int, partByte []byt
 }
 
 // file create
-func (u *Uploader) Create(uploadID string, blockList []string) (UploadResponse, error){
+func (u *Uploader) Create(uploadID string, blockList []string) (UploadResponse, error) {
+	return u.CreateWithContext(context.Background(), uploadID, blockList)
+}
+
+// file create，ctx取消时中断请求
+func (u *Uploader) CreateWithContext(ctx context.Context, uploadID string, blockList []string) (UploadResponse, error) {
 	ret := UploadResponse{}
 
-	fileInfo, err := u.getFileInfo()
+	fileInfo, err := u.getFileInfo(ctx)
 	if err != nil {
 		log.Println("getFileInfo failed, err:", err)
 		return ret, err
@@ -306,13 +524,13 @@ func (u *Uploader) Create(uploadID string, blockList []string) (UploadResponse,
 	v.Add("block_list", blockListStr)
 	v.Add("size", strconv.FormatInt(fileInfo.Size, 10))
 	v.Add("isdir", "0")
-	v.Add("rtype", "1")//1 为只要path冲突即重命名
+	v.Add("rtype", strconv.Itoa(u.rtype()))
 	body := v.Encode()
 
 	requestUrl := conf.OpenApiDomain + CreateUri + "&access_token=" + u.AccessToken
 
 	headers := make(map[string]string)
-	resp, err := httpclient.Post(requestUrl, headers, body)
+	resp, err := httpclient.PostWithContext(ctx, requestUrl, headers, body)
 	if err != nil {
 		log.Println("httpclient.Post failed, err:", err)
 		return ret, err
@@ -325,14 +543,85 @@ func (u *Uploader) Create(uploadID string, blockList []string) (UploadResponse,
 
 	if ret.ErrorCode != 0 {//错误码不为0
 		log.Println("file create failed, resp:", string(resp.Body))
+		if isConflict(ret.ErrorCode) {
+			return ret, &ConflictError{Path: u.Path, ErrorCode: ret.ErrorCode, ErrorMsg: ret.ErrorMsg}
+		}
 		return ret, errors.New(fmt.Sprintf("error_code:%d, error_msg:%s", ret.ErrorCode, ret.ErrorMsg))
 	}
 
 	return ret, nil
 }
 
-// 获取分片的大小
-func (u *Uploader) getSliceSize(fileSize int64) (int64, error) {
+// 网盘上的同名文件与要上传的文件大小和md5是否都相同，相同时返回网盘文件的信息
+func (u *Uploader) sameRemoteFile(ctx context.Context, fileInfo LocalFileInfo) (UploadResponse, bool, error) {
+	ret := UploadResponse{}
+	remote, err := NewFileClient(u.AccessToken).StatWithContext(ctx, u.Path)
+	if errors.Is(err, ErrFileNotExist) {
+		return ret, false, nil
+	}
+	if err != nil {
+		return ret, false, err
+	}
+	if remote.IsDir || remote.Size != fileInfo.Size || utils.DecryptMd5(remote.Md5) != fileInfo.Md5 {
+		return ret, false, nil
+	}
+
+	ret.Path = remote.Path
+	ret.Size = int(remote.Size)
+	ret.Ctime = remote.ServerCtime
+	ret.Mtime = remote.ServerMtime
+	ret.Md5 = remote.Md5
+	ret.FsID = remote.FsID
+	return ret, true, nil
+}
+
+// OnConflict对应接口的rtype参数
+func (u *Uploader) rtype() int {
+	switch u.OnConflict {
+	case OnConflictFail:
+		return 0// 0 为不重命名，返回冲突
+	case OnConflictOverwrite, OnConflictOverwriteIfDiff:
+		return 3// 3 为覆盖，OnConflictOverwriteIfDiff在上传前已经判断过内容是否相同
+	default:
+		return 1// 1 为只要path冲突即重命名
+	}
+}
+
+// 获取用户身份，获取失败时按普通用户处理，一次Upload中只获取一次
+func (u *Uploader) getVipType(ctx context.Context) int {
+	if u.vipType != nil {
+		return *u.vipType
+	}
+
+	vipType := 0
+	accountClient := account.NewAccountClient(u.AccessToken)
+	userInfo, err := accountClient.UserInfoWithContext(ctx)
+	if err != nil {//获取失败直接用4M
+		log.Println("account.UserInfo failed, err:", err)
+		u.vipTypeFailed = true
+	} else {
+		vipType = userInfo.VipType
+	}
+	u.vipType = &vipType
+
+	return vipType
+}
+
+// 清除上次获取失败时的默认用户身份，获取成功的结果继续使用
+func (u *Uploader) resetFailedVipType() {
+	if u.vipTypeFailed {
+		u.vipType = nil
+		u.vipTypeFailed = false
+	}
+}
+
+// 缓存的分片大小是否可以继续使用，获取用户身份失败时无法判断，继续使用缓存的结果，避免重新计算整个文件
+func (u *Uploader) isValidCachedSliceSize(vipType int, info *LocalFileInfo) bool {
+	return u.vipTypeFailed || isValidSliceSize(vipType, info.Size, info.SliceSize)
+}
+
+// 根据用户身份获取分片的大小
+func sliceSizeByVipType(vipType int, fileSize int64) int64 {
 	var sliceSize int64
 
 	/*
@@ -341,17 +630,10 @@ func (u *Uploader) getSliceSize(fileSize int64) (int64, error) {
 		普通会员用户单个分片大小上限为16MB，单文件总大小上限为10G。
 		超级会员用户单个分片大小上限为32MB，单文件总大小上限为20G。
 	*/
-	//切割文件，单个分片大小暂时先固定为4M，TODO 普通会员和超级会员单个分片可以更大，需判断用户的身份
 	sliceSize = 4194304//4M
-	accountClient := account.NewAccountClient(u.AccessToken)
-	userInfo, err := accountClient.UserInfo()
-	if err != nil {//获取失败直接用4M
-		log.Println("account.UserInfo failed, err:", err)
-		return sliceSize, nil
-	}
-	if userInfo.VipType == 1 {//普通会员
+	if vipType == 1 {//普通会员
 		sliceSize = 16777216//16M
-	} else if userInfo.VipType == 2 {//超级会员
+	} else if vipType == 2 {//超级会员
 		sliceSize = 33554432//32M
 	}
 
@@ -359,88 +641,116 @@ func (u *Uploader) getSliceSize(fileSize int64) (int64, error) {
 		sliceSize = fileSize
 	}
 
-	return sliceSize, nil
+	return sliceSize
 }
 
-// 获取block_list
-func (u *Uploader) getBlockList() ([]string, error) {
-	blockList := []string{}
+// 分片大小是否符合用户身份的限制，普通用户固定为4M，会员不小于4M且不超过上限，文件只有一个分片时不超过上限即可
+func isValidSliceSize(vipType int, fileSize, sliceSize int64) bool {
+	maxSliceSize := sliceSizeByVipType(vipType, math.MaxInt64)
+	if sliceSize >= fileSize {
+		return fileSize <= maxSliceSize && (sliceSize <= maxSliceSize || sliceSize == fileSize)
+	}
+	if vipType != 1 && vipType != 2 {
+		return sliceSize == maxSliceSize
+	}
+	return sliceSize >= 4194304 && sliceSize <= maxSliceSize
+}
 
-	filePath := u.LocalFilePath
+// 获取文件信息，只读取一次文件，同时计算content-md5、slice-md5和block_list，结果会被缓存
+func (u *Uploader) getFileInfo(ctx context.Context) (LocalFileInfo, error) {
+	info := LocalFileInfo{}
 
-	fileInfo, err := u.getFileInfo()
-	if err != nil {
-		log.Println("getFileInfo failed, err:", err)
-		return blockList, err
+	if u.reader != nil {//通过reader上传时在prepareReader中计算
+		if u.FileInfo == nil {
+			return info, errors.New("reader is not prepared")
+		}
+		return *u.FileInfo, nil
 	}
-	fileSize := fileInfo.Size
-	fileMd5 := fileInfo.Md5
 
-	sliceSize, err := u.getSliceSize(fileSize)
+	stat, err := os.Stat(u.LocalFilePath)
 	if err != nil {
-		log.Println("getSliceSize failed, err:", err)
-		return blockList, err
+		return info, err
 	}
 
-	if sliceSize == fileSize {//只有一个分片
-		blockList = append(blockList, fileMd5)
-		return blockList, nil
+	if u.FileInfo != nil && u.FileInfo.Size == stat.Size() && u.FileInfo.Mtime == stat.ModTime().UnixNano() {
+		vipType := u.getVipType(ctx)
+		if u.isValidCachedSliceSize(vipType, u.FileInfo) {
+			return *u.FileInfo, nil
+		}
+		log.Printf("FileInfo.SliceSize[%d] is invalid for vipType[%d], recompute", u.FileInfo.SliceSize, vipType)
 	}
 
-	buffer := make([]byte, sliceSize)
-	file, err := os.Open(filePath)
+	vipType := u.getVipType(ctx)
+	info, err = NewLocalFileInfo(u.LocalFilePath, sliceSizeByVipType(vipType, stat.Size()))
 	if err != nil {
-		return blockList, err
+		log.Println("NewLocalFileInfo failed, err:", err)
+		return info, err
 	}
-	defer file.Close()
+	u.FileInfo = &info
 
-	for {
-		n, err := file.Read(buffer[:])
-		if err != nil && err != io.EOF {
-			log.Println("file.Read failed, err:", err)
-			return blockList, err
-		}
-		if n == 0 {
-			break
-		}
-		partBuffer := buffer[0:n]
-		hash := md5.New()
-		hash.Write(partBuffer)
-		sliceMd5 := hex.EncodeToString(hash.Sum(nil))
-		blockList = append(blockList, sliceMd5)
-	}
+	return info, nil
+}
 
-	return blockList, nil
+// 获取block_list
+func (u *Uploader) getBlockList(ctx context.Context) ([]string, error) {
+	fileInfo, err := u.getFileInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo.BlockList, nil
 }
 
-// 获取文件信息
-func (u *Uploader) getFileInfo() (LocalFileInfo, error) {
-	info := LocalFileInfo{}
+// 获取分片的md5值
+func (u *Uploader) getSliceMd5(ctx context.Context) (string, error) {
+	fileInfo, err := u.getFileInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fileInfo.SliceMd5, nil
+}
 
-	filePath := u.LocalFilePath
-	file, err := os.Open(filePath)
+// 获取要上传文件的md5、分片md5等信息，sliceSize为分片大小，可以提前计算后设置到Uploader.FileInfo，避免上传时再次读取文件
+// 上传时文件的大小或修改时间与计算时不一致，或者分片大小不符合用户身份的限制时会重新计算
+func NewLocalFileInfo(localFilePath string, sliceSize int64) (LocalFileInfo, error) {
+	file, err := os.Open(localFilePath)
 	if err != nil {
-		return info, err
+		return LocalFileInfo{}, err
 	}
 	defer file.Close()
-	fileInfo, err := file.Stat()
+
+	// 在读取前获取修改时间，读取过程中文件被修改时下次上传会重新计算
+	stat, err := file.Stat()
 	if err != nil {
-		return info, err
+		return LocalFileInfo{}, err
 	}
 
-	info.Size= fileInfo.Size()
-
-	fileMd5, err := php2go.Md5File(filePath)
+	info, err := hashReader(file, sliceSize)
 	if err != nil {
-		log.Println("php2go.Md5File failed, err:", err)
 		return info, err
 	}
-
-	info.Md5 = fileMd5
+	info.Mtime = stat.ModTime().UnixNano()
 
 	return info, nil
 }
 
+// 计算并缓存要上传文件的md5、分片md5等信息，可以保存后在下次上传时设置到Uploader.FileInfo
+// 通过不能随机读取的reader上传时会缓存到临时文件，之后不调用Upload时需要调用Close删除
+func (u *Uploader) ComputeFileInfo() (LocalFileInfo, error) {
+	return u.ComputeFileInfoWithContext(context.Background())
+}
+
+// 计算并缓存要上传文件的md5、分片md5等信息，可以保存后在下次上传时设置到Uploader.FileInfo，ctx取消时中断请求
+func (u *Uploader) ComputeFileInfoWithContext(ctx context.Context) (LocalFileInfo, error) {
+	u.resetFailedVipType()
+	if u.reader != nil {
+		if err := u.prepareReader(ctx); err != nil {
+			u.cleanupReader()
+			return LocalFileInfo{}, err
+		}
+	}
+	return u.getFileInfo(ctx)
+}
+
 // 特殊字符处理，文件名里有特殊字符时无法上传到网盘，特殊字符有'\\', '?', '|', '"', '>', '<', ':', '*',"\t","\n","\r","\0","\x0B"
 func handleSpecialChar(char string) string {
 	specialChars := []string{"\\\\", "?", "|", "\"", ">", "<", ":", "*","\t","\n","\r","\\0","\\x0B"}
@@ -456,39 +766,3 @@ func handleSpecialChar(char string) string {
 
 	return newChar
 }
-
-// 获取分片的md5值
-func (u *Uploader) getSliceMd5() (string, error) {
-	var sliceMd5 string
-	var sliceSize int64
-	sliceSize = 262144//切割的块大小，固定为256KB
-
-	filePath := u.LocalFilePath
-	fileInfo, err := u.getFileInfo()
-	if err != nil {
-		log.Println("getFileInfo failed, err:", err)
-		return sliceMd5, err
-	}
-
-	fileSize := fileInfo.Size
-	fileMd5 := fileInfo.Md5
-
-	if fileSize <= sliceSize {
-		sliceMd5 = fileMd5
-	} else {
-		file, err := os.Open(filePath)
-		if err != nil {
-			return sliceMd5, err
-		}
-		defer file.Close()
-
-		partBuffer := make([]byte, sliceSize)
-		if _, err := file.Read(partBuffer); err == nil {
-			hash := md5.New()
-			hash.Write(partBuffer)
-			sliceMd5 = hex.EncodeToString(hash.Sum(nil))
-		}
-	}
-
-	return sliceMd5, nil
-}
\ No newline at end of file
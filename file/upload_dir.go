@@ -0,0 +1,301 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jsyzchen/pan/utils"
+	"github.com/jsyzchen/pan/utils/ratelimit"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// 上传目录时符号链接的处理方式
+const (
+	SymlinkSkip = iota //跳过，默认
+	SymlinkFollow //上传链接指向的文件或目录
+)
+
+// 文件被跳过的原因
+const (
+	SkipReasonExcluded    = "excluded" //匹配Exclude
+	SkipReasonNotIncluded = "not included" //不匹配Include
+	SkipReasonSymlink     = "symlink" //符号链接
+	SkipReasonIrregular   = "irregular file" //设备、管道等非普通文件
+	SkipReasonUnchanged   = "unchanged" //网盘已存在大小和md5相同的文件
+	SkipReasonLoop        = "symlink loop" //符号链接指向了已上传的目录
+)
+
+// 上传目录的参数
+type UploadDirOptions struct {
+	Include []string //只上传匹配的文件，glob格式，匹配相对路径或文件名，为空时上传所有文件
+	Exclude []string //不上传匹配的文件和目录，glob格式，匹配相对路径或文件名，优先于Include
+	Symlink int //符号链接的处理方式，默认为SymlinkSkip
+	Concurrency int //同时上传的文件数，默认为4
+	ForceUpload bool //为true时网盘已存在大小和md5相同的文件也重新上传
+	OnConflict int //网盘已存在同名文件时的处理策略，默认为OnConflictRename
+	MaxRetries int //单个分片上传失败后的最大重试次数
+	Limiter *ratelimit.Limiter //上传限速，限制的是所有文件的总速度，为空时使用File.Limiter
+	OnFileDone func(item UploadDirItem, status int) //每个文件处理结束后回调，status为UploadDirUploaded等，不会并发执行
+}
+
+// 目录中单个文件的处理结果
+const (
+	UploadDirUploaded = dirFileDone
+	UploadDirSkipped = dirFileSkipped
+	UploadDirFailed = dirFileFailed
+)
+
+// 目录中单个文件或目录的上传结果
+type UploadDirItem struct {
+	LocalPath string
+	Path string //网盘路径
+	Size int64
+	Reason string //跳过的原因
+	Err error //失败的原因
+}
+
+// 上传目录的结果汇总
+type UploadDirReport struct {
+	Uploaded []UploadDirItem
+	Skipped []UploadDirItem
+	Failed []UploadDirItem
+}
+
+// 上传本地目录到网盘，目录结构保持不变，包括空目录
+// 单个文件失败不影响其他文件，所有文件处理结束后返回的error说明失败的数量，每个文件的结果在UploadDirReport中
+func (f *File) UploadDir(localDir, remoteDir string, opts UploadDirOptions) (UploadDirReport, error) {
+	return f.UploadDirWithContext(context.Background(), localDir, remoteDir, opts)
+}
+
+// 上传本地目录到网盘，目录结构保持不变，包括空目录，ctx取消时中断请求
+// 单个文件失败不影响其他文件，所有文件处理结束后返回的error说明失败的数量，每个文件的结果在UploadDirReport中
+func (f *File) UploadDirWithContext(ctx context.Context, localDir, remoteDir string, opts UploadDirOptions) (UploadDirReport, error) {
+	report := UploadDirReport{}
+	defer f.ClearStatCache()
+
+	stat, err := os.Stat(localDir)
+	if err != nil {
+		return report, err
+	}
+	if !stat.IsDir() {
+		return report, errors.New(fmt.Sprintf("localDir[%s] is not a directory", localDir))
+	}
+
+	remoteDir = path.Clean("/" + remoteDir)
+	if err := f.MkdirAllWithContext(ctx, remoteDir); err != nil {
+		log.Println("MkdirAll failed, err:", err)
+		return report, err
+	}
+
+	// 网盘已存在的文件，用于跳过相同的文件以及已存在的目录
+	remoteList, err := f.ListAllWithContext(ctx, remoteDir, ListAllOptions{})
+	if err != nil {
+		log.Println("ListAll failed, err:", err)
+		return report, err
+	}
+	remoteItems := make(map[string]ListItem, len(remoteList))
+	for _, item := range remoteList {
+		remoteItems[item.Path] = item
+	}
+
+	w := &dirUploader{
+		file: f,
+		opts: opts,
+		task: newDirTask(opts.Concurrency),
+		report: &report,
+		remoteItems: remoteItems,
+		vipType: f.dirVipType(ctx),
+		visited: make(map[string]bool),
+	}
+
+	if realDir, err := filepath.EvalSymlinks(localDir); err == nil {
+		w.visited[realDir] = true
+	}
+	walkErr := w.walk(ctx, localDir, "", remoteDir)
+	failedErr := w.task.wait("upload")
+
+	if walkErr != nil {
+		return report, walkErr
+	}
+
+	return report, failedErr
+}
+
+type dirUploader struct {
+	file *File
+	opts UploadDirOptions
+	task *dirTask
+	report *UploadDirReport
+	remoteItems map[string]ListItem
+	vipType int
+	visited map[string]bool //已遍历的目录的真实路径，防止符号链接循环
+}
+
+// 遍历本地目录，创建网盘目录，文件交给task上传
+func (w *dirUploader) walk(ctx context.Context, localDir, relDir, remoteDir string) error {
+	entries, err := ioutil.ReadDir(localDir)
+	if err != nil {
+		log.Println("ioutil.ReadDir failed, err:", err)
+		return err
+	}
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		name := entry.Name()
+		rel := path.Join(relDir, name)
+		item := UploadDirItem{
+			LocalPath: filepath.Join(localDir, name),
+			Path: path.Join(remoteDir, name),
+			Size: entry.Size(),
+		}
+
+		if matchAny(w.opts.Exclude, rel, name) {
+			w.skip(item, SkipReasonExcluded)
+			continue
+		}
+
+		mode := entry.Mode()
+		if mode&os.ModeSymlink != 0 {
+			if w.opts.Symlink != SymlinkFollow {
+				w.skip(item, SkipReasonSymlink)
+				continue
+			}
+			target, err := os.Stat(item.LocalPath)
+			if err != nil {
+				w.fail(item, err)
+				continue
+			}
+			mode = target.Mode()
+			item.Size = target.Size()
+		}
+
+		switch {
+		case mode.IsDir():
+			realDir, err := filepath.EvalSymlinks(item.LocalPath)
+			if err != nil {
+				w.fail(item, err)
+				continue
+			}
+			if w.visited[realDir] {
+				w.skip(item, SkipReasonLoop)
+				continue
+			}
+			w.visited[realDir] = true
+
+			if err := w.mkdir(ctx, item.Path); err != nil {
+				w.fail(item, err)
+				continue
+			}
+			if err := w.walk(ctx, item.LocalPath, rel, item.Path); err != nil {
+				w.fail(item, err)
+			}
+		case mode.IsRegular():
+			if len(w.opts.Include) > 0 && !matchAny(w.opts.Include, rel, name) {
+				w.skip(item, SkipReasonNotIncluded)
+				continue
+			}
+			w.submit(ctx, item)
+		default:
+			w.skip(item, SkipReasonIrregular)
+		}
+	}
+
+	return nil
+}
+
+// 创建网盘目录，已存在时直接返回
+func (w *dirUploader) mkdir(ctx context.Context, dirPath string) error {
+	if item, ok := w.remoteItems[handleSpecialChar(dirPath)]; ok && item.IsDir == 1 {
+		return nil
+	}
+	res, err := w.file.MkdirWithContext(ctx, dirPath)
+	if err != nil && res.ErrorCode != ErrnoFileExist {
+		log.Println("Mkdir failed, err:", err)
+		return err
+	}
+	return nil
+}
+
+// 上传单个文件，网盘已存在相同的文件时跳过
+func (w *dirUploader) uploadFile(ctx context.Context, item UploadDirItem) {
+	info, err := NewLocalFileInfo(item.LocalPath, sliceSizeByVipType(w.vipType, item.Size))
+	if err != nil {
+		w.fail(item, err)
+		return
+	}
+	item.Size = info.Size
+
+	if !w.opts.ForceUpload {
+		remote, ok := w.remoteItems[handleSpecialChar(item.Path)]
+		if ok && remote.IsDir == 0 && int64(remote.Size) == info.Size && utils.DecryptMd5(remote.Md5) == info.Md5 {
+			w.skip(item, SkipReasonUnchanged)
+			return
+		}
+	}
+
+	uploader := NewUploader(w.file.AccessToken, item.Path, item.LocalPath)
+	uploader.FileInfo = &info
+	uploader.vipType = &w.vipType
+	uploader.OnConflict = w.opts.OnConflict
+	uploader.MaxRetries = w.opts.MaxRetries
+	uploader.Limiter = w.file.limiter(w.opts.Limiter)
+	if _, err := uploader.UploadWithContext(ctx); err != nil {
+		w.fail(item, err)
+		return
+	}
+
+	w.done(item, UploadDirUploaded)
+}
+
+func (w *dirUploader) submit(ctx context.Context, item UploadDirItem) {
+	w.task.submit(func() {
+		w.uploadFile(ctx, item)
+	})
+}
+
+func (w *dirUploader) skip(item UploadDirItem, reason string) {
+	item.Reason = reason
+	w.done(item, UploadDirSkipped)
+}
+
+func (w *dirUploader) fail(item UploadDirItem, err error) {
+	log.Printf("upload failed, localPath[%s] err[%v]", item.LocalPath, err)
+	item.Err = err
+	w.done(item, UploadDirFailed)
+}
+
+func (w *dirUploader) done(item UploadDirItem, status int) {
+	w.task.done(status, func() {
+		switch status {
+		case UploadDirUploaded:
+			w.report.Uploaded = append(w.report.Uploaded, item)
+		case UploadDirSkipped:
+			w.report.Skipped = append(w.report.Skipped, item)
+		default:
+			w.report.Failed = append(w.report.Failed, item)
+		}
+
+		if w.opts.OnFileDone != nil {
+			w.opts.OnFileDone(item, status)
+		}
+	})
+}
+
+// rel或name是否匹配patterns中的任意一个
+func matchAny(patterns []string, rel, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package file
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// slice-md5为文件前256KB的md5
+const sliceMd5Size = 262144
+
+// 按分片大小计算每个分片的md5，用于生成block_list
+type blockHasher struct {
+	sliceSize int64
+	written   int64
+	hash      hash.Hash
+	blockList []string
+}
+
+func newBlockHasher(sliceSize int64) *blockHasher {
+	return &blockHasher{
+		sliceSize: sliceSize,
+		hash:      md5.New(),
+	}
+}
+
+func (h *blockHasher) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		chunk := p
+		if h.sliceSize > 0 && int64(len(chunk)) > h.sliceSize-h.written {
+			chunk = p[:h.sliceSize-h.written]
+		}
+		h.hash.Write(chunk)
+		h.written += int64(len(chunk))
+		p = p[len(chunk):]
+		if h.written == h.sliceSize {
+			h.blockList = append(h.blockList, hex.EncodeToString(h.hash.Sum(nil)))
+			h.hash.Reset()
+			h.written = 0
+		}
+	}
+	return n, nil
+}
+
+// 返回所有分片的md5，包括最后一个不完整的分片
+func (h *blockHasher) BlockList() []string {
+	if h.written > 0 {
+		h.blockList = append(h.blockList, hex.EncodeToString(h.hash.Sum(nil)))
+		h.hash.Reset()
+		h.written = 0
+	}
+	return h.blockList
+}
+
+// 只计算前limit字节的md5
+type prefixHasher struct {
+	limit int64
+	hash  hash.Hash
+}
+
+func (h *prefixHasher) Write(p []byte) (int, error) {
+	n := len(p)
+	if h.limit > 0 {
+		if int64(len(p)) > h.limit {
+			p = p[:h.limit]
+		}
+		h.hash.Write(p)
+		h.limit -= int64(len(p))
+	}
+	return n, nil
+}
+
+// 读取一次r，同时计算content-md5、slice-md5和block_list
+func hashReader(r io.Reader, sliceSize int64) (LocalFileInfo, error) {
+	info := LocalFileInfo{}
+
+	contentHash := md5.New()
+	sliceHash := &prefixHasher{limit: sliceMd5Size, hash: md5.New()}
+	blockHash := newBlockHasher(sliceSize)
+
+	size, err := io.Copy(io.MultiWriter(contentHash, sliceHash, blockHash), r)
+	if err != nil {
+		return info, err
+	}
+
+	info.Size = size
+	info.Md5 = hex.EncodeToString(contentHash.Sum(nil))
+	info.SliceSize = sliceSize
+	info.BlockList = blockHash.BlockList()
+	if len(info.BlockList) == 0 {//空文件
+		info.BlockList = []string{info.Md5}
+	}
+	if size <= sliceMd5Size {
+		info.SliceMd5 = info.Md5
+	} else {
+		info.SliceMd5 = hex.EncodeToString(sliceHash.hash.Sum(nil))
+	}
+
+	return info, nil
+}
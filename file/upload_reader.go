@@ -0,0 +1,169 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+)
+
+// 不能随机读取的reader默认最多缓存4G，与普通用户单个文件的大小上限相同
+const defaultMaxSpoolSize = 4294967296
+
+// 通过io.Reader上传，如管道、标准输入、数据库导出等，size为要上传的字节数，不能为负数
+// r实现了io.ReaderAt或io.Seeker时直接随机读取，否则会先缓存到内存（不超过一个分片时）或SpoolDir下的临时文件，并且不使用秒传
+func NewReaderUploader(accessToken, path string, r io.Reader, size int64) *Uploader {
+	return &Uploader{
+		AccessToken: accessToken,
+		Path: handleSpecialChar(path),// 处理特殊字符
+		MaxSpoolSize: defaultMaxSpoolSize,
+		reader: r,
+		readerSize: size,
+	}
+}
+
+// 使用File的AccessToken和Limiter创建通过io.Reader上传的Uploader
+func (f *File) NewReaderUploader(path string, r io.Reader, size int64) *Uploader {
+	u := NewReaderUploader(f.AccessToken, path, r, size)
+	u.Limiter = f.Limiter
+	return u
+}
+
+// 读取一次reader计算md5等信息，并准备好分片上传时随机读取的数据源
+func (u *Uploader) prepareReader(ctx context.Context) error {
+	if u.readerAt != nil {
+		return nil
+	}
+	if u.readerSize < 0 {//长度未知时需要调用方先读取完或指定长度
+		return errors.New(fmt.Sprintf("invalid reader size[%d], size must not be negative", u.readerSize))
+	}
+
+	vipType := u.getVipType(ctx)
+	sliceSize := sliceSizeByVipType(vipType, u.readerSize)
+
+	reader := u.reader
+	if f, ok := reader.(*os.File); ok {//管道、标准输入等不能随机读取
+		if stat, err := f.Stat(); err != nil || !stat.Mode().IsRegular() {
+			reader = struct{ io.Reader }{f}
+		}
+	}
+
+	var src io.Reader
+	var buf *bytes.Buffer
+	switch r := reader.(type) {
+	case io.ReaderAt:
+		u.readerAt = r
+		if u.hasFileInfo(vipType) {
+			return nil
+		}
+		src = io.NewSectionReader(r, 0, u.readerSize)
+	case io.ReadSeeker:
+		u.readerAt = &seekReaderAt{r: r}
+		if u.hasFileInfo(vipType) {
+			return nil
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		src = io.LimitReader(r, u.readerSize)
+	default:
+		// 预创建时需要完整的block_list，不能随机读取时只能先缓存
+		u.disableRapidUpload = true
+		if u.readerSize <= sliceSize {
+			buf = bytes.NewBuffer(make([]byte, 0, u.readerSize))
+			src = io.TeeReader(io.LimitReader(r, u.readerSize), buf)
+		} else {
+			if u.MaxSpoolSize > 0 && u.readerSize > u.MaxSpoolSize {
+				return errors.New(fmt.Sprintf("reader size[%d] exceeds MaxSpoolSize[%d]", u.readerSize, u.MaxSpoolSize))
+			}
+			spoolFile, err := ioutil.TempFile(u.SpoolDir, "pan_upload_")
+			if err != nil {
+				return err
+			}
+			u.spoolFile = spoolFile
+			u.readerAt = spoolFile
+			src = io.TeeReader(io.LimitReader(r, u.readerSize), spoolFile)
+		}
+	}
+
+	info, err := hashReader(src, sliceSize)
+	if err != nil {
+		log.Println("hashReader failed, err:", err)
+		return err
+	}
+	if info.Size != u.readerSize {
+		return errors.New(fmt.Sprintf("reader size mismatch, expect[%d] actual[%d]", u.readerSize, info.Size))
+	}
+	u.FileInfo = &info
+	if buf != nil {
+		u.readerAt = bytes.NewReader(buf.Bytes())
+	}
+
+	return nil
+}
+
+// 是否已经设置了可用的FileInfo，可以随机读取的reader不需要再次计算
+func (u *Uploader) hasFileInfo(vipType int) bool {
+	return u.FileInfo != nil && u.FileInfo.Size == u.readerSize && u.isValidCachedSliceSize(vipType, u.FileInfo)
+}
+
+// 删除通过reader上传时缓存的临时文件，Upload结束时会自动删除
+// 调用了ComputeFileInfo但不再调用Upload时需要调用Close
+func (u *Uploader) Close() error {
+	u.cleanupReader()
+	return nil
+}
+
+// 删除缓存的临时文件
+func (u *Uploader) cleanupReader() {
+	if u.spoolFile == nil {
+		return
+	}
+	u.spoolFile.Close()
+	if err := os.Remove(u.spoolFile.Name()); err != nil {
+		log.Println("remove spool file failed, err:", err)
+	}
+	u.spoolFile = nil
+	u.readerAt = nil
+}
+
+// 打开要上传的内容，返回的close用于释放资源
+func (u *Uploader) openSource() (io.ReaderAt, func() error, error) {
+	if u.reader != nil {
+		if u.readerAt == nil {
+			return nil, nil, errors.New("reader is not prepared")
+		}
+		return u.readerAt, func() error { return nil }, nil
+	}
+
+	file, err := os.Open(u.LocalFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file.Close, nil
+}
+
+// 通过Seek实现io.ReaderAt
+type seekReaderAt struct {
+	mu sync.Mutex
+	r  io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(s.r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,45 @@
+package file
+
+import (
+	"context"
+	"github.com/jsyzchen/pan/utils"
+	"log"
+)
+
+// 分片上传的默认重试次数，重试间隔见utils.RetryInterval
+const defaultMaxRetries = 3
+
+// 可以重试的superfile2错误码
+var retryableErrorCodes = map[int]bool{
+	31034: true, //命中接口频控
+}
+
+// 分片上传，网络错误或可重试的错误码按指数退避重试，ctx取消时立即返回
+func (u *Uploader) superFile2UploadWithRetry(ctx context.Context, uploadID string, partSeq int, partByte []byte) (SuperFile2UploadResponse, error) {
+	maxRetries := u.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := u.SuperFile2UploadWithContext(ctx, uploadID, partSeq, partByte)
+		if err == nil {
+			return resp, nil
+		}
+		u.tracker.ResetPart(partSeq)//失败的分片已发送的字节数不计入进度
+		if ctx.Err() != nil || attempt >= maxRetries || !isRetryable(resp) {
+			return resp, err
+		}
+
+		interval := utils.RetryInterval(attempt)
+		log.Printf("superfile2 upload retry, partseq[%d] attempt[%d] interval[%v] err[%v]", partSeq, attempt+1, interval, err)
+		if utils.SleepContext(ctx, interval) != nil {
+			return resp, err
+		}
+	}
+}
+
+// 错误码为0说明是网络错误或者返回内容无法解析
+func isRetryable(resp SuperFile2UploadResponse) bool {
+	return resp.ErrorCode == 0 || retryableErrorCodes[resp.ErrorCode]
+}
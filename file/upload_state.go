@@ -0,0 +1,144 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/syyongx/php2go"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 断点续传时保存的上传进度
+type UploadState struct {
+	UploadID      string         `json:"uploadid"`
+	Path          string         `json:"path"`
+	LocalFilePath string         `json:"local_file_path"`
+	Size          int64          `json:"size"`
+	Md5           string         `json:"md5"`
+	SliceSize     int64          `json:"slice_size"`
+	Parts         map[int]string `json:"parts"` // 已上传成功的分片，key为partseq，value为分片md5
+	CreatedAt     int64          `json:"created_at"`
+}
+
+// 上传进度的存储，Load在没有保存过进度时返回nil, nil
+type UploadStateStore interface {
+	Load(key string) (*UploadState, error)
+	Save(key string, state *UploadState) error
+	Delete(key string) error
+}
+
+// uploadid的有效期，超过后重新上传
+const uploadIDExpire = 7 * 24 * time.Hour
+
+// uploadid已失效，需要重新预创建
+var errUploadIDExpired = errors.New("uploadid expired")
+
+// 创建文件时返回分片缺失，说明uploadid已失效，已上传的分片被服务端清理
+func isUploadIDExpired(errorCode int) bool {
+	return errorCode == 31363
+}
+
+// 上传进度保存在本地目录，每个上传任务一个json文件
+type FileUploadStateStore struct {
+	Dir string
+}
+
+func NewFileUploadStateStore(dir string) *FileUploadStateStore {
+	return &FileUploadStateStore{
+		Dir: dir,
+	}
+}
+
+func (s *FileUploadStateStore) Load(key string) (*UploadState, error) {
+	data, err := ioutil.ReadFile(s.stateFilePath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	state := &UploadState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// 先写入临时文件再重命名，避免进程退出时状态文件不完整
+func (s *FileUploadStateStore) Save(key string, state *UploadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	stateFilePath := s.stateFilePath(key)
+	tmpFilePath := stateFilePath + ".tmp"
+	if err := ioutil.WriteFile(tmpFilePath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilePath, stateFilePath)
+}
+
+func (s *FileUploadStateStore) Delete(key string) error {
+	err := os.Remove(s.stateFilePath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileUploadStateStore) stateFilePath(key string) string {
+	return filepath.Join(s.Dir, key+".json")
+}
+
+// 上传任务的唯一标识，由网盘路径和本地文件路径决定
+func (u *Uploader) stateKey() string {
+	return php2go.Md5(u.Path + "\n" + u.LocalFilePath)
+}
+
+// 加载可以继续使用的上传进度，本地文件有变化或uploadid已过期时删除旧的进度
+func (u *Uploader) loadState(fileInfo LocalFileInfo) (*UploadState, error) {
+	if u.StateStore == nil {
+		return nil, nil
+	}
+
+	key := u.stateKey()
+	state, err := u.StateStore.Load(key)
+	if err != nil || state == nil {
+		return nil, err
+	}
+
+	if state.Size != fileInfo.Size || state.Md5 != fileInfo.Md5 || state.UploadID == "" ||
+		time.Since(time.Unix(state.CreatedAt, 0)) > uploadIDExpire {
+		return nil, u.StateStore.Delete(key)
+	}
+
+	if state.Parts == nil {
+		state.Parts = make(map[int]string)
+	}
+
+	return state, nil
+}
+
+func (u *Uploader) saveState(state *UploadState) error {
+	if u.StateStore == nil {
+		return nil
+	}
+	return u.StateStore.Save(u.stateKey(), state)
+}
+
+func (u *Uploader) deleteState() error {
+	if u.StateStore == nil {
+		return nil
+	}
+	return u.StateStore.Delete(u.stateKey())
+}
@@ -1,8 +1,21 @@
 package file
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"github.com/jsyzchen/pan/conf"
+	fileUtil "github.com/jsyzchen/pan/utils/file"
+	"github.com/jsyzchen/pan/utils/ratelimit"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestUpload(t *testing.T) {
@@ -14,3 +27,240 @@ func TestUpload(t *testing.T) {
 		t.Logf("TestUpload Success res: %+v", res)
 	}
 }
+
+func TestResumableUpload(t *testing.T) {
+	store := NewFileUploadStateStore(os.TempDir())
+	fileUploader := NewResumableUploader(conf.TestData.AccessToken, conf.TestData.Path, conf.TestData.LocalFilePath, store)
+	res, err := fileUploader.Upload()
+	if err != nil {
+		t.Fail()
+	} else {
+		t.Logf("TestResumableUpload Success res: %+v", res)
+	}
+}
+
+func TestReaderUpload(t *testing.T) {
+	content := "pan reader upload test"
+	fileUploader := NewReaderUploader(conf.TestData.AccessToken, conf.TestData.Path, strings.NewReader(content), int64(len(content)))
+	res, err := fileUploader.Upload()
+	if err != nil {
+		t.Fail()
+	} else {
+		t.Logf("TestReaderUpload Success res: %+v", res)
+	}
+}
+
+func TestUploader_ComputeFileInfo(t *testing.T) {
+	fileUploader := NewUploader(conf.TestData.AccessToken, conf.TestData.Path, conf.TestData.LocalFilePath)
+	res, err := fileUploader.ComputeFileInfo()
+	if err != nil {
+		t.Errorf("TestUploader_ComputeFileInfo failed, err:%v", err)
+	}
+	t.Logf("TestUploader_ComputeFileInfo res: %+v", res)
+}
+
+func TestUploader_FileInfoModified(t *testing.T) {
+	localFile, err := ioutil.TempFile("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(localFile.Name())
+	localFile.WriteString("old content")
+	localFile.Close()
+
+	fileUploader := NewUploader(conf.TestData.AccessToken, conf.TestData.Path, localFile.Name())
+	oldInfo, err := fileUploader.ComputeFileInfo()
+	if err != nil {
+		t.Fatalf("TestUploader_FileInfoModified failed, err:%v", err)
+	}
+
+	// 大小不变，只修改内容和修改时间
+	ioutil.WriteFile(localFile.Name(), []byte("new content"), 0644)
+	mtime := time.Now().Add(time.Minute)
+	os.Chtimes(localFile.Name(), mtime, mtime)
+	newInfo, err := fileUploader.ComputeFileInfo()
+	if err != nil {
+		t.Fatalf("TestUploader_FileInfoModified failed, err:%v", err)
+	}
+	if newInfo.Md5 == oldInfo.Md5 {
+		t.Errorf("TestUploader_FileInfoModified FileInfo not recomputed, md5:%s", newInfo.Md5)
+	}
+}
+
+func TestUploader_OnConflict(t *testing.T) {
+	fileUploader := NewUploader(conf.TestData.AccessToken, conf.TestData.Path, conf.TestData.LocalFilePath)
+	fileUploader.OnConflict = OnConflictFail
+	res, err := fileUploader.Upload()
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		t.Logf("TestUploader_OnConflict conflict: %v", conflictErr)
+	} else if err != nil {
+		t.Errorf("TestUploader_OnConflict failed, err:%v", err)
+	}
+	t.Logf("TestUploader_OnConflict res: %+v", res)
+}
+
+func TestUploader_OnConflictOverwriteIfDiff(t *testing.T) {
+	fileUploader := NewUploader(conf.TestData.AccessToken, conf.TestData.Path, conf.TestData.LocalFilePath)
+	fileUploader.OnConflict = OnConflictOverwriteIfDiff
+	first, err := fileUploader.Upload()
+	if err != nil {
+		t.Fatalf("TestUploader_OnConflictOverwriteIfDiff failed, err:%v", err)
+	}
+
+	// 内容相同，跳过上传，返回网盘上已有的文件
+	fileUploader = NewUploader(conf.TestData.AccessToken, conf.TestData.Path, conf.TestData.LocalFilePath)
+	fileUploader.OnConflict = OnConflictOverwriteIfDiff
+	second, err := fileUploader.Upload()
+	if err != nil {
+		t.Fatalf("TestUploader_OnConflictOverwriteIfDiff failed, err:%v", err)
+	}
+	if second.FsID != first.FsID || second.Path != first.Path {
+		t.Errorf("TestUploader_OnConflictOverwriteIfDiff expected same file, first:%+v second:%+v", first, second)
+	}
+}
+
+func TestUploader_MaxRetries(t *testing.T) {
+	fileUploader := NewUploader(conf.TestData.AccessToken, conf.TestData.Path, conf.TestData.LocalFilePath)
+	fileUploader.MaxRetries = 5
+	res, err := fileUploader.Upload()
+	if err != nil {
+		t.Errorf("TestUploader_MaxRetries failed, err:%v", err)
+	}
+	t.Logf("TestUploader_MaxRetries res: %+v", res)
+}
+
+func TestUploader_OnProgress(t *testing.T) {
+	fileUploader := NewUploader(conf.TestData.AccessToken, conf.TestData.Path, conf.TestData.LocalFilePath)
+	fileUploader.OnProgress = fileUtil.ProgressFunc(func(p fileUtil.Progress) {
+		t.Logf("TestUploader_OnProgress %d/%d, speed:%.0f", p.TransferredBytes, p.TotalBytes, p.Speed)
+	})
+	res, err := fileUploader.Upload()
+	if err != nil {
+		t.Errorf("TestUploader_OnProgress failed, err:%v", err)
+	}
+	t.Logf("TestUploader_OnProgress res: %+v", res)
+}
+
+func TestUploader_Limiter(t *testing.T) {
+	fileUploader := NewUploader(conf.TestData.AccessToken, conf.TestData.Path, conf.TestData.LocalFilePath)
+	fileUploader.Limiter = ratelimit.NewLimiter(1048576)
+	res, err := fileUploader.Upload()
+	if err != nil {
+		t.Errorf("TestUploader_Limiter failed, err:%v", err)
+	}
+	t.Logf("TestUploader_Limiter res: %+v", res)
+}
+
+func TestUploader_UploadWithContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	fileUploader := NewUploader(conf.TestData.AccessToken, conf.TestData.Path, conf.TestData.LocalFilePath)
+	_, err := fileUploader.UploadWithContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TestUploader_UploadWithContext expected context.DeadlineExceeded, err:%v", err)
+	}
+}
+
+func TestFile_UploadDir(t *testing.T) {
+	fileClient := NewFileClient(conf.TestData.AccessToken)
+	localDir := filepath.Dir(conf.TestData.LocalFilePath)
+	report, err := fileClient.UploadDir(localDir, conf.TestData.Dir, UploadDirOptions{Exclude: []string{".*"}})
+	if err != nil {
+		t.Errorf("TestFile_UploadDir failed, err:%v", err)
+	}
+	t.Logf("TestFile_UploadDir uploaded:%d skipped:%d failed:%d", len(report.Uploaded), len(report.Skipped), len(report.Failed))
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashReader(t *testing.T) {
+	data := make([]byte, 300000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		sliceSize int64
+		expectSliceMd5 string
+		expectBlockList []string
+	}{
+		{"empty", nil, 4194304, "d41d8cd98f00b204e9800998ecf8427e", []string{"d41d8cd98f00b204e9800998ecf8427e"}},
+		{"hello", []byte("hello"), 4194304, "5d41402abc4b2a76b9719d911017c592", []string{"5d41402abc4b2a76b9719d911017c592"}},
+		{"one slice", data, 300000, md5Hex(data[:sliceMd5Size]), []string{md5Hex(data)}},
+		{"exact slices", data, 100000, md5Hex(data[:sliceMd5Size]), []string{md5Hex(data[:100000]), md5Hex(data[100000:200000]), md5Hex(data[200000:])}},
+		{"last slice smaller", data, 128000, md5Hex(data[:sliceMd5Size]), []string{md5Hex(data[:128000]), md5Hex(data[128000:256000]), md5Hex(data[256000:])}},
+		{"shorter than slice-md5", data[:1000], 400, md5Hex(data[:1000]), []string{md5Hex(data[:400]), md5Hex(data[400:800]), md5Hex(data[800:1000])}},
+	}
+
+	for _, tt := range tests {
+		info, err := hashReader(bytes.NewReader(tt.data), tt.sliceSize)
+		if err != nil {
+			t.Errorf("%s: hashReader failed, err:%v", tt.name, err)
+			continue
+		}
+		if info.Size != int64(len(tt.data)) || info.Md5 != md5Hex(tt.data) || info.SliceSize != tt.sliceSize {
+			t.Errorf("%s: got size:%d md5:%s sliceSize:%d", tt.name, info.Size, info.Md5, info.SliceSize)
+		}
+		if info.SliceMd5 != tt.expectSliceMd5 {
+			t.Errorf("%s: slice-md5 %s, expect %s", tt.name, info.SliceMd5, tt.expectSliceMd5)
+		}
+		if !reflect.DeepEqual(info.BlockList, tt.expectBlockList) {
+			t.Errorf("%s: block_list %v, expect %v", tt.name, info.BlockList, tt.expectBlockList)
+		}
+
+		// 分多次写入，写入的边界与分片边界不对齐
+		h := newBlockHasher(tt.sliceSize)
+		for p := tt.data; len(p) > 0; {
+			n := 7777
+			if n > len(p) {
+				n = len(p)
+			}
+			h.Write(p[:n])
+			p = p[n:]
+		}
+		blockList := h.BlockList()
+		if len(tt.data) > 0 && !reflect.DeepEqual(blockList, tt.expectBlockList) {
+			t.Errorf("%s: blockHasher block_list %v, expect %v", tt.name, blockList, tt.expectBlockList)
+		}
+	}
+}
+
+func TestIsValidSliceSize(t *testing.T) {
+	const m = 1048576
+	tests := []struct {
+		vipType int
+		fileSize int64
+		sliceSize int64
+		expect bool
+	}{
+		{0, 10 * m, 4 * m, true},
+		{0, 10 * m, 8 * m, false},
+		{0, 10 * m, 2 * m, false},
+		{0, 10 * m, 16 * m, false},
+		{0, 3 * m, 3 * m, true},
+		{0, 3 * m, 4 * m, true},
+		{0, 0, 0, true},
+		{1, 100 * m, 16 * m, true},
+		{1, 100 * m, 8 * m, true},
+		{1, 100 * m, 4 * m, true},
+		{1, 100 * m, 2 * m, false},
+		{1, 100 * m, 32 * m, false},
+		{1, 10 * m, 10 * m, true},
+		{1, 20 * m, 20 * m, false},
+		{2, 100 * m, 32 * m, true},
+		{2, 100 * m, 64 * m, false},
+		{2, 30 * m, 64 * m, false},
+	}
+
+	for _, tt := range tests {
+		if got := isValidSliceSize(tt.vipType, tt.fileSize, tt.sliceSize); got != tt.expect {
+			t.Errorf("isValidSliceSize(%d, %d, %d) = %v, expect %v", tt.vipType, tt.fileSize, tt.sliceSize, got, tt.expect)
+		}
+	}
+}
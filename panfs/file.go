@@ -0,0 +1,123 @@
+//go:build go1.16
+// +build go1.16
+
+package panfs
+
+import (
+	"github.com/jsyzchen/pan/file"
+	fileUtil "github.com/jsyzchen/pan/utils/file"
+	"io"
+	"io/fs"
+)
+
+// 网盘文件，通过dlink的Range请求读取
+type remoteFile struct {
+	fsys   *FS
+	name   string
+	info   *fileInfo
+	remote *fileUtil.RemoteFile // 第一次读取时创建
+	offset int64
+}
+
+var (
+	_ io.ReaderAt = (*remoteFile)(nil)
+	_ io.Seeker   = (*remoteFile)(nil)
+)
+
+func (f *remoteFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *remoteFile) Read(p []byte) (int, error) {
+	if f.offset >= f.info.Size() {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, err := f.readAt("read", p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (f *remoteFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if off >= f.info.Size() {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	return f.readAt("readat", p, off)
+}
+
+func (f *remoteFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+
+	return offset, nil
+}
+
+func (f *remoteFile) Close() error {
+	f.remote = nil
+	return nil
+}
+
+// 通过RemoteFile读取，预读的数据缓存在RemoteFile中
+func (f *remoteFile) readAt(op string, p []byte, off int64) (int, error) {
+	if f.remote == nil {
+		downloader, err := f.fsys.downloader(f.info)
+		if err != nil {
+			return 0, &fs.PathError{Op: op, Path: f.name, Err: err}
+		}
+		remote, err := fileUtil.NewRemoteFileWithContext(f.fsys.context(), downloader)
+		if err != nil {
+			return 0, &fs.PathError{Op: op, Path: f.name, Err: err}
+		}
+		f.remote = remote
+	}
+
+	n, err := f.remote.ReadAtWithContext(f.fsys.context(), p, off)
+	if err != nil && err != io.EOF {
+		return n, &fs.PathError{Op: op, Path: f.name, Err: err}
+	}
+
+	return n, err
+}
+
+// 通过Metas获取文件的下载地址
+func (fsys *FS) downloader(info *fileInfo) (*fileUtil.Downloader, error) {
+	metas, err := fsys.client.MetasWithOptionsWithContext(fsys.context(), []uint64{info.info.FsID}, file.MetasOptions{DLink: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(metas.List) == 0 || metas.List[0].DLink == "" {
+		return nil, file.ErrFileNotExist
+	}
+
+	downloadLink := metas.List[0].DLink + "&access_token=" + fsys.client.AccessToken
+	downloader := fileUtil.NewFileDownloader(downloadLink, "")
+	downloader.FileSize = int(info.Size())
+	downloader.Limiter = fsys.client.Limiter
+
+	return downloader, nil
+}
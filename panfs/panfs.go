@@ -0,0 +1,246 @@
+//go:build go1.16
+// +build go1.16
+
+// 将网盘目录封装为io/fs.FS，可以直接用于fs.WalkDir、http.FS、template.ParseFS、fs.Glob等
+package panfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/jsyzchen/pan/file"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+var (
+	errIsDir  = errors.New("is a directory")
+	errNotDir = errors.New("not a directory")
+)
+
+// 网盘文件系统，只读
+type FS struct {
+	client *file.File
+	root   string
+	ctx    context.Context
+}
+
+var (
+	_ fs.FS         = (*FS)(nil)
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+)
+
+// 以网盘目录root为根目录创建文件系统，如 panfs.New(fileClient, "/apps/书梯")
+func New(client *file.File, root string) *FS {
+	return &FS{
+		client: client,
+		root:   path.Clean("/" + root),
+	}
+}
+
+// 返回使用ctx发送请求的文件系统，ctx取消后打开、读取文件等操作都会返回错误
+// 已经打开的文件仍然使用打开时的ctx
+func (fsys *FS) WithContext(ctx context.Context) *FS {
+	fsys2 := *fsys
+	fsys2.ctx = ctx
+	return &fsys2
+}
+
+// 打开文件或目录
+func (fsys *FS) Open(name string) (fs.File, error) {
+	info, err := fsys.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &dir{fsys: fsys, name: name, info: info}, nil
+	}
+
+	return &remoteFile{fsys: fsys, name: name, info: info}, nil
+}
+
+// 获取文件信息
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	return fsys.stat("stat", name)
+}
+
+// 获取目录下的文件，按文件名排序
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := fsys.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	return fsys.readDir(name)
+}
+
+// 读取整个文件
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	info, err := fsys.stat("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errIsDir}
+	}
+	if info.Size() == 0 {
+		return []byte{}, nil
+	}
+
+	downloader, err := fsys.downloader(info)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, info.Size()))
+	if _, err := downloader.DownloadTo(fsys.context(), buf); err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (fsys *FS) context() context.Context {
+	if fsys.ctx == nil {
+		return context.Background()
+	}
+	return fsys.ctx
+}
+
+// name转换为网盘路径
+func (fsys *FS) fullPath(name string) string {
+	return path.Join(fsys.root, name)
+}
+
+func (fsys *FS) stat(op, name string) (*fileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := fsys.client.StatWithContext(fsys.context(), fsys.fullPath(name))
+	if err != nil {
+		if errors.Is(err, file.ErrFileNotExist) {
+			err = fs.ErrNotExist
+		}
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	return &fileInfo{info: info, name: path.Base(name)}, nil
+}
+
+func (fsys *FS) readDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	it := fsys.client.ListIterWithContext(fsys.context(), fsys.fullPath(name), file.ListOptions{})
+	for it.Next() {
+		item := it.Item()
+		entries = append(entries, &fileInfo{info: item.FileInfo(), name: item.ServerFileName})
+	}
+	if err := it.Err(); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// 文件信息，同时实现了fs.FileInfo和fs.DirEntry
+type fileInfo struct {
+	info file.FileInfo
+	name string
+}
+
+func (fi *fileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *fileInfo) Size() int64 {
+	return fi.info.Size
+}
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.info.IsDir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.info.ModTime()
+}
+
+func (fi *fileInfo) IsDir() bool {
+	return fi.info.IsDir
+}
+
+// 返回file.FileInfo
+func (fi *fileInfo) Sys() interface{} {
+	return fi.info
+}
+
+func (fi *fileInfo) Type() fs.FileMode {
+	return fi.Mode().Type()
+}
+
+func (fi *fileInfo) Info() (fs.FileInfo, error) {
+	return fi, nil
+}
+
+// 目录
+type dir struct {
+	fsys    *FS
+	name    string
+	info    *fileInfo
+	entries []fs.DirEntry
+	loaded  bool
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+// 实现fs.ReadDirFile，n<=0时返回剩余的所有文件
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.loaded {
+		entries, err := d.fsys.readDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.loaded = entries, true
+	}
+
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+
+	return rest[:n], nil
+}
@@ -0,0 +1,53 @@
+//go:build go1.16
+// +build go1.16
+
+package panfs
+
+import (
+	"github.com/jsyzchen/pan/conf"
+	"github.com/jsyzchen/pan/file"
+	"io/fs"
+	"path"
+	"testing"
+)
+
+func TestFS_ReadDir(t *testing.T) {
+	fsys := New(file.NewFileClient(conf.TestData.AccessToken), conf.TestData.Dir)
+	res, err := fsys.ReadDir(".")
+	if err != nil {
+		t.Errorf("TestFS_ReadDir failed, err:%v", err)
+	}
+	t.Logf("TestFS_ReadDir res: %+v", res)
+}
+
+func TestFS_Stat(t *testing.T) {
+	fsys := New(file.NewFileClient(conf.TestData.AccessToken), path.Dir(conf.TestData.Path))
+	res, err := fs.Stat(fsys, path.Base(conf.TestData.Path))
+	if err != nil {
+		t.Errorf("TestFS_Stat failed, err:%v", err)
+	}
+	t.Logf("TestFS_Stat res: %+v", res)
+}
+
+func TestFS_ReadFile(t *testing.T) {
+	fsys := New(file.NewFileClient(conf.TestData.AccessToken), path.Dir(conf.TestData.Path))
+	res, err := fs.ReadFile(fsys, path.Base(conf.TestData.Path))
+	if err != nil {
+		t.Errorf("TestFS_ReadFile failed, err:%v", err)
+	}
+	t.Logf("TestFS_ReadFile len: %d", len(res))
+}
+
+func TestFS_WalkDir(t *testing.T) {
+	fsys := New(file.NewFileClient(conf.TestData.AccessToken), conf.TestData.Dir)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		t.Logf("TestFS_WalkDir path: %s", p)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("TestFS_WalkDir failed, err:%v", err)
+	}
+}
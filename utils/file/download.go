@@ -1,19 +1,22 @@
 package file
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/jsyzchen/pan/utils"
+	"github.com/jsyzchen/pan/utils/ratelimit"
 	"io"
 	"io/ioutil"
 	"log"
 	"math"
 	"net/http"
 	"os"
-	"path"
 	"path/filepath"
 	"strconv"
 	"sync"
-	"time"
 )
 
 //FileDownloader 文件下载器
@@ -25,6 +28,18 @@ type Downloader struct {
 	DoneFilePart   []Part
 	PartSize int
 	PartCoroutineNum int //分片下载协程数
+	OnProgress ProgressListener //下载进度回调，为空时不回调
+	Limiter *ratelimit.Limiter //下载限速，多个下载器共享同一个Limiter时限制总速度，为空时不限速
+	Resumable bool //断点续传，下载中的文件和进度保存在FilePath旁边，中断后再次下载只下载缺失的部分
+	Md5 string //文件的md5，不为空时下载完成后校验，支持网盘接口返回的加密md5，不一致时返回*ChecksumError
+	RefreshLink func() (string, error) //下载地址失效时获取新的下载地址，为空时不刷新
+	MaxRetries int //单个分片下载失败后的最大重试次数，0时使用默认值3，小于0时不重试，失败的分片通过*MultiError返回
+	tracker *ProgressTracker
+	linkMu sync.Mutex
+	stateMu sync.Mutex
+	state *downloadState
+	partMd5 map[int]string //每个分片下载时计算的md5
+	hasher *orderedHasher
 }
 
 //filePart 文件分片
@@ -32,8 +47,8 @@ type Part struct {
 	Index int    //文件分片的序号
 	From  int    //开始byte
 	To    int    //解决byte
-	Data  []byte //http下载得到的文件内容
-	FilePath string //下载到本地的分片文件路径
+	Data  []byte //Deprecated: 分片直接写入目标文件，不再使用
+	FilePath string //Deprecated: 分片直接写入目标文件，不再使用
 }
 
 //NewFileDownloader .
@@ -61,11 +76,16 @@ func (d *Downloader) SetCoroutineNum(partCoroutineNum int) {
 
 //Run 开始下载任务
 func (d *Downloader) Download() error {
+	return d.DownloadWithContext(context.Background())
+}
+
+// 开始下载任务，ctx取消时中断所有分片的请求并返回ctx.Err()，未开启断点续传时删除下载中的临时文件
+func (d *Downloader) DownloadWithContext(ctx context.Context) error {
 	if d.TotalPart == 1 {
-		err := d.downloadWhole()
+		err := d.downloadWholeWithVerify(ctx)
 		return err
 	}
-	isSupportRange, err := d.head()
+	isSupportRange, err := d.head(ctx)
 	if err != nil {
 		return err
 	}
@@ -79,7 +99,7 @@ func (d *Downloader) Download() error {
 	log.Println("fileTotalSize:", fileTotalSize)
 
 	if isSupportRange == false || fileTotalSize <= d.PartSize {//不支持Range下载或者文件比较小，直接下载文件
-		err := d.downloadWhole()
+		err := d.downloadWholeWithVerify(ctx)
 		return err
 	}
 
@@ -101,6 +121,8 @@ func (d *Downloader) Download() error {
 
 	log.Println("eachSize:", eachSize)
 
+	partSizes := make([]int64, d.TotalPart)
+
 	for i := range jobs {
 		jobs[i].Index = i
 		if i == 0 {
@@ -114,51 +136,205 @@ func (d *Downloader) Download() error {
 			//the last filePart
 			jobs[i].To = fileTotalSize - 1
 		}
+		partSizes[i] = int64(jobs[i].To - jobs[i].From + 1)
 	}
+	d.tracker = NewProgressTracker(d.OnProgress, partSizes)
 
-	// 删除临时文件
-	defer d.removePartFiles()
+	// 分片直接写入预分配大小的临时文件，下载完成后再重命名为FilePath，中断时不会在FilePath留下不完整的文件
+	out, err := d.openPartialFile()
+	if err != nil {
+		log.Println("openPartialFile failed, err:", err)
+		return err
+	}
+	defer out.Close()
 
-	var wg sync.WaitGroup
-	isFailed := false
+	done := make([]bool, len(jobs))
+	if d.Resumable {
+		if done, err = d.prepareResume(out, jobs); err != nil {
+			return err
+		}
+	}
+	if err := out.Truncate(int64(fileTotalSize)); err != nil {
+		return err
+	}
+	if d.Md5 != "" {//下载时按文件顺序计算md5，断点续传前已下载的分片从文件中读取
+		d.hasher = newOrderedHasher(out, jobs)
+		for _, job := range jobs {
+			if done[job.Index] {
+				d.hasher.partDone(job.Index)
+			}
+		}
+	}
+
+	var pending []Part
+	for _, job := range jobs {
+		if !done[job.Index] {//断点续传时已下载完成的分片不再下载
+			pending = append(pending, job)
+		}
+	}
+	if err := d.downloadParts(ctx, out, pending); err != nil {
+		if !d.Resumable {
+			d.removePartialFile(out)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	if d.Md5 != "" {
+		if err := d.verify(ctx, out, jobs); err != nil {
+			log.Println("verify failed, err:", err)
+			d.removePartialFile(out)
+			if d.Resumable {//内容有误，不能继续使用
+				d.deleteState()
+			}
+			return err
+		}
+	}
+
+	if err := d.commitPartialFile(out); err != nil {
+		return err
+	}
+	if d.Resumable {
+		d.deleteState()
+	}
+
+	return nil
+}
+
+// 并发下载分片
+func (d *Downloader) downloadParts(ctx context.Context, out io.WriterAt, jobs []Part) error {
 	partCoroutineNum := d.PartCoroutineNum
 	if len(jobs) < partCoroutineNum {
 		partCoroutineNum = len(jobs)
 	}
-	sem := make(chan int, partCoroutineNum) //限制并发数，以防大文件下载导致占用服务器大量网络宽带和磁盘io
+	g := newPartGroup(ctx, partCoroutineNum) //限制并发数，以防大文件下载导致占用服务器大量网络宽带和磁盘io
 	for _, job := range jobs {
-		wg.Add(1)
-		sem <- 1 //当通道已满的时候将被阻塞
-		go func(job Part) {
-			defer wg.Done()
-			err := d.downloadPart(job)
-			if err != nil {
-				log.Println("下载文件失败:", err, job)
-				isFailed = true //TODO 可能会有问题
+		job := job
+		g.Go(job, func() *PartError {
+			d.tracker.SetPartState(job.Index, PartRunning)
+			partErr := d.downloadPart(ctx, out, job)
+			if partErr != nil {
+				log.Println("下载文件失败:", partErr)
+				d.tracker.SetPartState(job.Index, PartFailed)
+			} else {
+				d.tracker.SetPartState(job.Index, PartDone)
 			}
-			<-sem
-		}(job)
+			return partErr
+		})
 	}
-	wg.Wait()
-	if isFailed == true {
+	if err := g.Wait(); err != nil {
 		log.Println("下载文件失败")
-		return errors.New("downloadPart failed")
+		return err
+	}
+
+	return nil
+}
+
+// 下载过程中写入的临时文件，下载完成后重命名为FilePath
+func (d *Downloader) partialFilePath() string {
+	return d.FilePath + partialFileSuffix
+}
+
+// 打开下载的临时文件，已存在时保留原有内容，用于断点续传
+func (d *Downloader) openPartialFile() (*os.File, error) {
+	//存储文件夹不存在的话先创建文件夹
+	if err := os.MkdirAll(filepath.Dir(d.FilePath), os.ModePerm); err != nil {
+		log.Println("MkdirAll failed:", err)
+		return nil, err
+	}
+
+	return os.OpenFile(d.partialFilePath(), os.O_RDWR|os.O_CREATE, 0644)
+}
+
+// 写入磁盘后重命名为FilePath
+func (d *Downloader) commitPartialFile(out *os.File) error {
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(d.partialFilePath(), d.FilePath)
+}
+
+// 下载失败时删除临时文件
+func (d *Downloader) removePartialFile(out *os.File) {
+	out.Close()
+	if err := os.Remove(d.partialFilePath()); err != nil && !os.IsNotExist(err) {
+		log.Println("remove partial file failed, err:", err)
+	}
+}
+
+// 断点续传时加载下载进度，返回已下载完成的分片，远程文件变化或临时文件不完整时从头下载
+func (d *Downloader) prepareResume(out *os.File, jobs []Part) ([]bool, error) {
+	done := make([]bool, len(jobs))
+
+	state := d.loadState()
+	if state != nil {
+		if stat, err := out.Stat(); err != nil || stat.Size() != int64(d.FileSize) {
+			log.Println("partial file is incomplete, restart download")
+			state = nil
+		}
+	}
+	if state == nil {
+		state = &downloadState{Size: d.FileSize, TotalPart: d.TotalPart}
+		if err := d.saveState(state); err != nil {
+			log.Println("saveState failed, err:", err)
+			return done, err
+		}
+	}
+	d.state = state
+
+	for _, job := range jobs {
+		if state.contains(Range{From: job.From, To: job.To}) {
+			if partMd5, ok := state.PartMd5[job.Index]; ok {
+				if d.partMd5 == nil {
+					d.partMd5 = make(map[int]string)
+				}
+				d.partMd5[job.Index] = partMd5
+			}
+			done[job.Index] = true
+			d.DoneFilePart[job.Index] = job
+			d.tracker.SetPartDone(job.Index)
+		}
+	}
+
+	return done, nil
+}
+
+// 记录下载完成的分片和分片的md5，断点续传时保存下载进度
+func (d *Downloader) markPartDone(c Part, partMd5 string) {
+	d.stateMu.Lock()
+	defer d.stateMu.Unlock()
+	if d.partMd5 == nil {
+		d.partMd5 = make(map[int]string)
+	}
+	d.partMd5[c.Index] = partMd5
+	if d.state == nil {
+		return
 	}
 
-	return d.mergeFileParts()
+	if d.state.PartMd5 == nil {
+		d.state.PartMd5 = make(map[int]string)
+	}
+	d.state.PartMd5[c.Index] = partMd5
+	d.state.add(Range{From: c.From, To: c.To})
+	if err := d.saveState(d.state); err != nil {
+		log.Println("saveState failed, err:", err)
+	}
 }
 
 //head 获取要下载的文件的基本信息(header) 使用HTTP Method Head
-func (d *Downloader) head() (bool, error) {
+func (d *Downloader) head(ctx context.Context) (bool, error) {
 	isSupportRange := false
-	r, err := d.getNewRequest("HEAD")
-	if err != nil {
-		return isSupportRange, err
-	}
-	resp, err := http.DefaultClient.Do(r)
+	resp, err := d.doRequest(ctx, "HEAD", "")
 	if err != nil {
 		return isSupportRange, err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode > 299 {
 		return isSupportRange, errors.New(fmt.Sprintf("Can't process, response is %v", resp))
 	}
@@ -177,161 +353,166 @@ func (d *Downloader) head() (bool, error) {
 	return isSupportRange, nil
 }
 
-//下载分片
-func (d *Downloader) downloadPart(c Part) error {
-	r, err := d.getNewRequest("GET")
-	if err != nil {
-		return err
-	}
+//下载分片，直接写入out中分片对应的位置
+func (d *Downloader) downloadPart(ctx context.Context, out io.WriterAt, c Part) *PartError {
 	log.Printf("开始[%d]下载from:%d to:%d\n", c.Index, c.From, c.To)
-	r.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", c.From, c.To))
-	resp, err := http.DefaultClient.Do(r)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	bs, err := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode > 299 {
-		log.Println(fmt.Sprintf("服务器错误，状态码: %v, msg:%s", resp.StatusCode, string(bs)))
-		return errors.New(fmt.Sprintf("服务器错误，状态码: %v, msg:%s", resp.StatusCode, string(bs)))
+	partHash := md5.New()
+	if partErr := d.downloadPartWithRetry(ctx, d.hasher.writerAt(out, c.Index), c, partHash); partErr != nil {
+		return partErr
 	}
 
-	if err != nil {
-		if err != io.EOF && err != io.ErrUnexpectedEOF {//unexpected EOF 处理
-			log.Println("ioutil.ReadAll error :", err)
-			return err
+	if f, ok := out.(*os.File); ok && d.state != nil {//保证记录下载进度前分片已经写入磁盘
+		if err := f.Sync(); err != nil {
+			log.Println(err)
+			return &PartError{Index: c.Index, From: c.From, To: c.To, Written: int64(c.To - c.From + 1), Err: err}
 		}
 	}
 
-	if len(bs) != (c.To - c.From + 1) {
-		return errors.New(fmt.Sprintf("下载文件分片长度错误, len bs:%d", len(bs)))
-	}
-	//c.Data = bs
-
-	//分片文件写入到本地临时目录
-	fileName := path.Base(d.FilePath)
-	fileNamePrefix := fileName[0:len(path.Base(d.FilePath)) - len(path.Ext(d.FilePath))]
-	nowTime := time.Now().UnixNano() / 1e6
-	partFilePath := path.Join(os.TempDir(), fileNamePrefix + "_" + strconv.Itoa(c.Index) + "_" + strconv.FormatInt(nowTime, 10))
+	d.DoneFilePart[c.Index] = c
+	d.markPartDone(c, hex.EncodeToString(partHash.Sum(nil)))
 
-	log.Printf("partFilePath[%d]:%s", c.Index, partFilePath)
+	log.Printf("结束[%d]下载from:%d to:%d\n", c.Index, c.From, c.To)
+	return nil
+}
 
-	f, err := os.Create(partFilePath)
+// 下载分片中跳过前offset个字节后的剩余部分，写入的内容同时写入partHash，返回本次写入的字节数
+func (d *Downloader) downloadPartFrom(ctx context.Context, out io.WriterAt, c Part, offset int64, partHash io.Writer) (int64, error) {
+	from := int64(c.From) + offset
+	ctx, watcher, cancel := withStallTimeout(ctx)
+	defer cancel()
+	resp, err := d.doRequest(ctx, "GET", fmt.Sprintf("bytes=%v-%v", from, c.To))
 	if err != nil {
-		log.Println("open file error :", err)
-		return err
+		return 0, err
 	}
+	defer resp.Body.Close()
 
-	// 关闭文件
-	defer f.Close()
-	// 字节方式写入
-	_, err = f.Write(bs)
-	if err != nil {
-		log.Println(err)
-		return err
+	if resp.StatusCode != http.StatusPartialContent && !(resp.StatusCode == http.StatusOK && from == 0) {
+		bs, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		log.Println(fmt.Sprintf("服务器错误，状态码: %v, msg:%s", resp.StatusCode, string(bs)))
+		return 0, &StatusError{StatusCode: resp.StatusCode, Msg: string(bs)}
 	}
 
-	c.FilePath = partFilePath
-
-	d.DoneFilePart[c.Index] = c
+	size := int64(c.To+1) - from
+	body := d.Limiter.Reader(ctx, watcher.Reader(resp.Body))
+	if c.Index >= 0 {//序号小于0的分片不计入下载进度
+		body = d.tracker.Reader(c.Index, body)
+	}
+	n, err := io.Copy(&offsetWriter{w: out, offset: from}, io.TeeReader(io.LimitReader(body, size), partHash))
+	if err != nil {
+		log.Println("io.Copy error :", err)
+		return n, err
+	}
+	if n != size {
+		return n, errors.New(fmt.Sprintf("下载文件分片长度错误, len:%d, %v", n, io.ErrUnexpectedEOF))
+	}
 
-	log.Printf("结束[%d]下载from:%d to:%d\n", c.Index, c.From, c.To)
-	return nil
+	return n, nil
 }
 
-//mergeFileParts 合并下载的文件
-func (d *Downloader) mergeFileParts() error {
-	log.Println("开始合并文件")
+// 下载指定范围的数据并写入w，from和to都包含在内，返回写入的字节数
+func (d *Downloader) DownloadRange(w io.Writer, from, to int64) (int64, error) {
+	return d.DownloadRangeWithContext(context.Background(), w, from, to)
+}
 
-	//存储文件夹不存在的话先创建文件夹
-	fileDir := filepath.Dir(d.FilePath)
-	_, err := os.Stat(fileDir)
+// 下载指定范围的数据并写入w，from和to都包含在内，返回写入的字节数，ctx取消时中断请求
+func (d *Downloader) DownloadRangeWithContext(ctx context.Context, w io.Writer, from, to int64) (int64, error) {
+	ctx, watcher, cancel := withStallTimeout(ctx)
+	defer cancel()
+	resp, err := d.doRequest(ctx, "GET", fmt.Sprintf("bytes=%v-%v", from, to))
 	if err != nil {
-		if os.IsNotExist(err){
-			//递归创建文件夹
-			err := os.MkdirAll(fileDir, os.ModePerm)
-			if err != nil{
-				log.Println("MkdirAll failed:", err)
-				return err
-			}
-		}
+		return 0, err
 	}
+	defer resp.Body.Close()
 
-	mergedFile, err := os.Create(d.FilePath)
-	if err != nil {
-		return err
+	if resp.StatusCode != http.StatusPartialContent && !(resp.StatusCode == http.StatusOK && from == 0) {
+		bs, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, &StatusError{StatusCode: resp.StatusCode, Msg: string(bs)}
 	}
-	defer mergedFile.Close()
-	totalSize := 0
-	for _, s := range d.DoneFilePart {
-		data, err := ioutil.ReadFile(s.FilePath)
-		if err != nil {
-			log.Println("ioutil.ReadFile err:", err)
-			return err
-		}
 
-		mergedFile.Write(data)
-		totalSize += len(data)
+	size := to - from + 1
+	n, err := io.Copy(w, io.LimitReader(d.Limiter.Reader(ctx, watcher.Reader(resp.Body)), size))
+	if err != nil {
+		return n, err
 	}
-	if totalSize != d.FileSize {
-		return errors.New("文件不完整")
+	if n != size {
+		return n, errors.New(fmt.Sprintf("下载文件分片长度错误, len:%d", n))
 	}
-	return nil
+
+	return n, nil
 }
 
-// 删除临时文件
-func (d *Downloader) removePartFiles() {
-	var wg sync.WaitGroup
-	for _, s := range d.DoneFilePart {
-		if s.FilePath != "" {
-			wg.Add(1)
-			go func (filePath string) {
-				defer wg.Done()
-				if err := os.Remove(filePath); err != nil {
-					log.Println(filePath, "remove failed, err:", err)
-				}
-			}(s.FilePath)
-		}
+//直接下载整个文件，md5校验失败时重新下载一次
+func (d *Downloader) downloadWholeWithVerify(ctx context.Context) error {
+	err := d.downloadWhole(ctx)
+	if _, ok := err.(*ChecksumError); ok {
+		log.Println("checksum mismatch, download again, err:", err)
+		return d.downloadWhole(ctx)
 	}
-	wg.Wait()
+	return err
 }
 
 //直接下载整个文件
-func (d *Downloader) downloadWhole() error {
+func (d *Downloader) downloadWhole(ctx context.Context) error {
 	log.Println("downloadWhole")
 
 	// Get the data
-	r, err := d.getNewRequest("GET")
-	if err != nil {
-		return err
-	}
-	resp, err := http.DefaultClient.Do(r)
+	ctx, watcher, cancel := withStallTimeout(ctx)
+	defer cancel()
+	resp, err := d.doRequest(ctx, "GET", "")
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	// 创建一个文件用于保存
-	out, err := os.Create(d.FilePath)
+	if resp.StatusCode > 299 {
+		bs, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{StatusCode: resp.StatusCode, Msg: string(bs)}
+	}
+
+	// 创建一个文件用于保存，下载完成后再重命名为FilePath
+	out, err := d.openPartialFile()
 	if err != nil {
 		return err
 	}
 	defer out.Close()
+	if err := out.Truncate(0); err != nil {
+		return err
+	}
 
-	// 然后将响应流和文件流对接起来
-	_, err = io.Copy(out, resp.Body)
+	size := resp.ContentLength
+	if size < 0 {//大小未知
+		size = 0
+	}
+	d.tracker = NewProgressTracker(d.OnProgress, []int64{size})
+	d.tracker.SetPartState(0, PartRunning)
+
+	// 然后将响应流和文件流对接起来，同时计算md5
+	fileHash := md5.New()
+	_, err = io.Copy(out, io.TeeReader(d.tracker.Reader(0, d.Limiter.Reader(ctx, watcher.Reader(resp.Body))), fileHash))
 	if err != nil {
+		d.tracker.SetPartState(0, PartFailed)
+		d.removePartialFile(out)
 		return err
 	}
+	d.tracker.SetPartState(0, PartDone)
 
-	return nil
+	if d.Md5 != "" {
+		expected, actual := utils.DecryptMd5(d.Md5), hex.EncodeToString(fileHash.Sum(nil))
+		if actual != expected {
+			d.removePartialFile(out)
+			return &ChecksumError{Path: d.FilePath, Expected: expected, Actual: actual}
+		}
+	}
+
+	return d.commitPartialFile(out)
 }
 
-// getNewRequest 创建一个request
-func (d *Downloader) getNewRequest(method string) (*http.Request, error) {
-	r, err := http.NewRequest(
+// newRequest 创建一个request
+func (d *Downloader) newRequest(ctx context.Context, method, link string) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(
+		ctx,
 		method,
-		d.Link,
+		link,
 		nil,
 	)
 	if err != nil {
@@ -340,4 +521,19 @@ func (d *Downloader) getNewRequest(method string) (*http.Request, error) {
 
 	r.Header.Set("User-Agent", "pan.baidu.com")
 	return r, nil
-}
\ No newline at end of file
+}
+
+// 从offset开始依次写入w
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (ow *offsetWriter) Write(p []byte) (int, error) {
+	n, err := ow.w.WriteAt(p, ow.offset)
+	ow.offset += int64(n)
+	if err != nil {
+		return n, &writeError{err: err}
+	}
+	return n, nil
+}
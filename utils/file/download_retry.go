@@ -0,0 +1,249 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jsyzchen/pan/utils"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 分片下载的默认重试次数，重试间隔见utils.RetryInterval
+const defaultPartMaxRetries = 3
+
+// 读取响应内容时连续stallTimeout没有收到数据则中断请求，避免连接挂起时一直阻塞，中断后按网络错误重试
+const stallTimeout = 60 * time.Second
+
+var errStalled = errors.New(fmt.Sprintf("no data received in %v", stallTimeout))
+
+// 下载请求返回了非预期的状态码
+type StatusError struct {
+	StatusCode int
+	Msg        string //响应内容的前1024个字节
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("服务器错误，状态码: %v, msg:%s", e.StatusCode, e.Msg)
+}
+
+// 单个分片重试后仍然下载失败
+type PartError struct {
+	Index   int
+	From    int
+	To      int
+	Written int64 //最后一次失败时该分片已经写入的字节数
+	Err     error
+}
+
+func (e *PartError) Error() string {
+	return fmt.Sprintf("part[%d] bytes=%d-%d failed, written[%d]: %v", e.Index, e.From, e.To, e.Written, e.Err)
+}
+
+func (e *PartError) Unwrap() error {
+	return e.Err
+}
+
+// 所有下载失败的分片，按分片序号排序，可以通过errors.As获取后逐个检查
+type MultiError struct {
+	Errors []*PartError
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, partErr := range e.Errors {
+		msgs[i] = partErr.Error()
+	}
+	return fmt.Sprintf("%d parts download failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// errors.Is匹配任意一个分片的错误
+func (e *MultiError) Is(target error) bool {
+	for _, partErr := range e.Errors {
+		if errors.Is(partErr, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// errors.As匹配任意一个分片的错误，返回第一个匹配的
+func (e *MultiError) As(target interface{}) bool {
+	for _, partErr := range e.Errors {
+		if errors.As(partErr, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// 失败的分片序号
+func (e *MultiError) Parts() []int {
+	parts := make([]int, len(e.Errors))
+	for i, partErr := range e.Errors {
+		parts[i] = partErr.Index
+	}
+	return parts
+}
+
+// 并发下载分片，限制并发数，收集所有分片的错误，单个分片失败不影响其他分片，ctx取消后不再开始新的分片
+type partGroup struct {
+	ctx  context.Context
+	wg   sync.WaitGroup
+	sem  chan struct{}
+	mu   sync.Mutex
+	errs []*PartError
+}
+
+func newPartGroup(ctx context.Context, limit int) *partGroup {
+	if limit <= 0 {
+		limit = 1
+	}
+	return &partGroup{ctx: ctx, sem: make(chan struct{}, limit)}
+}
+
+// 执行job的下载函数f，并发数已满时阻塞，直到有分片完成，ctx取消后不再执行，直接记录为失败
+func (g *partGroup) Go(job Part, f func() *PartError) {
+	select {
+	case g.sem <- struct{}{}:
+	case <-g.ctx.Done():
+		g.mu.Lock()
+		g.errs = append(g.errs, &PartError{Index: job.Index, From: job.From, To: job.To, Err: g.ctx.Err()})
+		g.mu.Unlock()
+		return
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() { <-g.sem }()
+		if err := f(); err != nil {
+			g.mu.Lock()
+			g.errs = append(g.errs, err)
+			g.mu.Unlock()
+		}
+	}()
+}
+
+// 等待所有分片结束，有分片失败时返回*MultiError
+func (g *partGroup) Wait() error {
+	g.wg.Wait()
+	if len(g.errs) == 0 {
+		return nil
+	}
+	sort.Slice(g.errs, func(i, j int) bool {
+		return g.errs[i].Index < g.errs[j].Index
+	})
+	return &MultiError{Errors: g.errs}
+}
+
+// 写入本地文件失败，重试没有意义
+type writeError struct {
+	err error
+}
+
+func (e *writeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *writeError) Unwrap() error {
+	return e.err
+}
+
+// 下载分片，失败时按指数退避重试，重试时从上次收到的最后一个字节之后继续下载，ctx取消时立即返回
+// partHash在多次尝试之间共享，返回时包含该分片所有已写入的内容
+func (d *Downloader) downloadPartWithRetry(ctx context.Context, out io.WriterAt, c Part, partHash io.Writer) *PartError {
+	maxRetries := d.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultPartMaxRetries
+	}
+
+	written := int64(0)
+	for attempt := 0; ; attempt++ {
+		n, err := d.downloadPartFrom(ctx, out, c, written, partHash)
+		written += n
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return &PartError{Index: c.Index, From: c.From, To: c.To, Written: written, Err: ctx.Err()}
+		}
+		if attempt >= maxRetries || !isRetryableDownloadErr(err) {
+			return &PartError{Index: c.Index, From: c.From, To: c.To, Written: written, Err: err}
+		}
+
+		interval := utils.RetryInterval(attempt)
+		log.Printf("download part retry, index[%d] written[%d] attempt[%d] interval[%v] err[%v]", c.Index, written, attempt+1, interval, err)
+		if err := utils.SleepContext(ctx, interval); err != nil {
+			return &PartError{Index: c.Index, From: c.From, To: c.To, Written: written, Err: err}
+		}
+	}
+}
+
+// 网络错误、数据不完整、服务端错误以及频控可以重试，写入本地文件失败以及其他状态码不重试
+func isRetryableDownloadErr(err error) bool {
+	var writeErr *writeError
+	if errors.As(err, &writeErr) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	return true
+}
+
+// 读取响应内容超时时取消请求
+type stallWatcher struct {
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	stalled int32
+}
+
+// 返回的ctx用于发送请求，请求结束后需要调用cancel
+func withStallTimeout(ctx context.Context) (context.Context, *stallWatcher, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &stallWatcher{cancel: cancel}
+	w.timer = time.AfterFunc(stallTimeout, func() {
+		atomic.StoreInt32(&w.stalled, 1)
+		cancel()
+	})
+	w.timer.Stop()
+	return ctx, w, func() {
+		w.timer.Stop()
+		cancel()
+	}
+}
+
+// 包装r，每次读取的等待时间不能超过stallTimeout，限速等待的时间不计算在内
+func (w *stallWatcher) Reader(r io.Reader) io.Reader {
+	return &stallReader{r: r, w: w}
+}
+
+// 因超时取消请求时返回errStalled
+func (w *stallWatcher) err(err error) error {
+	if err != nil && atomic.LoadInt32(&w.stalled) == 1 {
+		return errStalled
+	}
+	return err
+}
+
+type stallReader struct {
+	r io.Reader
+	w *stallWatcher
+}
+
+func (sr *stallReader) Read(p []byte) (int, error) {
+	sr.w.timer.Reset(stallTimeout)
+	n, err := sr.r.Read(p)
+	sr.w.timer.Stop()
+	return n, sr.w.err(err)
+}
@@ -0,0 +1,147 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMultiError_IsAs(t *testing.T) {
+	multiErr := &MultiError{Errors: []*PartError{
+		{Index: 1, Err: context.DeadlineExceeded},
+		{Index: 3, Err: &StatusError{StatusCode: 500}},
+		{Index: 4, Err: &StatusError{StatusCode: 404}},
+	}}
+	var err error = multiErr
+
+	tests := []struct {
+		target error
+		expect bool
+	}{
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{io.ErrUnexpectedEOF, false},
+	}
+	for _, tt := range tests {
+		if got := errors.Is(err, tt.target); got != tt.expect {
+			t.Errorf("errors.Is(%v), got %v, expect %v", tt.target, got, tt.expect)
+		}
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != 500 {
+		t.Errorf("errors.As StatusError, got %v, expect the first one with status 500", statusErr)
+	}
+	var partErr *PartError
+	if !errors.As(err, &partErr) || partErr.Index != 1 {
+		t.Errorf("errors.As PartError, got %v, expect part 1", partErr)
+	}
+	var checksumErr *ChecksumError
+	if errors.As(err, &checksumErr) {
+		t.Errorf("errors.As ChecksumError, got %v, expect no match", checksumErr)
+	}
+
+	if parts := multiErr.Parts(); !reflect.DeepEqual(parts, []int{1, 3, 4}) {
+		t.Errorf("Parts, got %v, expect [1 3 4]", parts)
+	}
+}
+
+func TestPartGroup(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name   string
+		limit  int
+		failed []int //返回错误的分片
+		expect []int //MultiError中的分片，按序号排序
+	}{
+		{"all succeed", 2, nil, nil},
+		{"one failed", 2, []int{3}, []int{3}},
+		{"sorted", 3, []int{5, 0, 2}, []int{0, 2, 5}},
+		{"zero limit", 0, []int{1}, []int{1}},
+	}
+
+	for _, tt := range tests {
+		failed := make(map[int]bool)
+		for _, index := range tt.failed {
+			failed[index] = true
+		}
+		maxRunning := tt.limit
+		if maxRunning <= 0 {
+			maxRunning = 1
+		}
+
+		var running, peak int32
+		g := newPartGroup(context.Background(), tt.limit)
+		for i := 0; i < 6; i++ {
+			job := Part{Index: i, From: i * 10, To: i*10 + 9}
+			g.Go(job, func() *PartError {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				if failed[job.Index] {
+					return &PartError{Index: job.Index, From: job.From, To: job.To, Err: errFailed}
+				}
+				return nil
+			})
+		}
+		err := g.Wait()
+
+		if peak > int32(maxRunning) {
+			t.Errorf("%s: %d parts ran concurrently, limit %d", tt.name, peak, maxRunning)
+		}
+		if len(tt.expect) == 0 {
+			if err != nil {
+				t.Errorf("%s: got %v, expect nil", tt.name, err)
+			}
+			continue
+		}
+		var multiErr *MultiError
+		if !errors.As(err, &multiErr) {
+			t.Errorf("%s: got %v, expect *MultiError", tt.name, err)
+			continue
+		}
+		if parts := multiErr.Parts(); !reflect.DeepEqual(parts, tt.expect) {
+			t.Errorf("%s: failed parts %v, expect %v", tt.name, parts, tt.expect)
+		}
+		if !errors.Is(err, errFailed) {
+			t.Errorf("%s: errors.Is(errFailed) is false", tt.name)
+		}
+	}
+}
+
+func TestPartGroup_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	g := newPartGroup(ctx, 1)
+	g.sem <- struct{}{} //占满并发数，保证Go走到ctx取消的分支
+	for i := 0; i < 3; i++ {
+		g.Go(Part{Index: i}, func() *PartError {
+			called = true
+			return nil
+		})
+	}
+	err := g.Wait()
+
+	if called {
+		t.Error("part started after ctx canceled")
+	}
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) || !reflect.DeepEqual(multiErr.Parts(), []int{0, 1, 2}) {
+		t.Fatalf("got %v, expect all parts failed", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("errors.Is(context.Canceled) is false, err: %v", err)
+	}
+}
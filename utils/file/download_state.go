@@ -0,0 +1,168 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jsyzchen/pan/utils/httpclient"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+)
+
+// 断点续传时保存在FilePath+downloadStateSuffix中的下载进度
+type downloadState struct {
+	Size      int     `json:"size"`
+	TotalPart int     `json:"total_part"`
+	Ranges    []Range `json:"ranges"` //已下载完成的范围，from和to都包含在内
+	PartMd5   map[int]string `json:"part_md5"` //已下载完成的分片下载时计算的md5，用于校验失败时找出写入有误的分片
+}
+
+// 文件中的一段范围，from和to都包含在内
+type Range struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+const (
+	downloadStateSuffix = ".state"
+	partialFileSuffix = ".partial" //下载中的文件
+)
+
+func (d *Downloader) stateFilePath() string {
+	return d.FilePath + downloadStateSuffix
+}
+
+// 加载下载进度，文件大小或分片方式变化时返回nil
+func (d *Downloader) loadState() *downloadState {
+	data, err := ioutil.ReadFile(d.stateFilePath())
+	if err != nil {
+		return nil
+	}
+
+	state := &downloadState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		log.Println("json.Unmarshal download state failed, err:", err)
+		return nil
+	}
+
+	if state.Size != d.FileSize || state.TotalPart != d.TotalPart {
+		log.Printf("remote file changed, size[%d=>%d] totalPart[%d=>%d], restart download", state.Size, d.FileSize, state.TotalPart, d.TotalPart)
+		return nil
+	}
+
+	return state
+}
+
+// 先写入临时文件再重命名，避免进程退出时状态文件不完整
+func (d *Downloader) saveState(state *downloadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmpFilePath := d.stateFilePath() + ".tmp"
+	if err := ioutil.WriteFile(tmpFilePath, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilePath, d.stateFilePath())
+}
+
+func (d *Downloader) deleteState() {
+	if err := os.Remove(d.stateFilePath()); err != nil && !os.IsNotExist(err) {
+		log.Println("remove download state failed, err:", err)
+	}
+}
+
+// r是否已经下载完成
+func (state *downloadState) contains(r Range) bool {
+	for _, done := range state.Ranges {
+		if done.From <= r.From && r.To <= done.To {
+			return true
+		}
+	}
+	return false
+}
+
+// 记录下载完成的范围，相邻的范围会被合并
+func (state *downloadState) add(r Range) {
+	ranges := make([]Range, 0, len(state.Ranges)+1)
+	inserted := false
+	for _, done := range state.Ranges {
+		if !inserted && r.From < done.From {
+			ranges = append(ranges, r)
+			inserted = true
+		}
+		ranges = append(ranges, done)
+	}
+	if !inserted {
+		ranges = append(ranges, r)
+	}
+
+	merged := ranges[:1]
+	for _, next := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if next.From <= last.To+1 {
+			if next.To > last.To {
+				last.To = next.To
+			}
+			continue
+		}
+		merged = append(merged, next)
+	}
+	state.Ranges = merged
+}
+
+// 当前使用的下载地址
+func (d *Downloader) link() string {
+	d.linkMu.Lock()
+	defer d.linkMu.Unlock()
+	return d.Link
+}
+
+// 下载地址失效时通过RefreshLink获取新的地址，oldLink为请求失败时使用的地址，并发的分片只会刷新一次
+func (d *Downloader) refreshLink(oldLink string) bool {
+	if d.RefreshLink == nil {
+		return false
+	}
+
+	d.linkMu.Lock()
+	defer d.linkMu.Unlock()
+	if d.Link != oldLink {//其他分片已经刷新过
+		return true
+	}
+
+	link, err := d.RefreshLink()
+	if err != nil || link == "" {
+		log.Println("RefreshLink failed, err:", err)
+		return false
+	}
+	d.Link = link
+	return true
+}
+
+// 发送请求，rangeHeader不为空时设置Range，下载地址失效时刷新地址后重试一次
+func (d *Downloader) doRequest(ctx context.Context, method, rangeHeader string) (*http.Response, error) {
+	for refreshed := false; ; refreshed = true {
+		link := d.link()
+		r, err := d.newRequest(ctx, method, link)
+		if err != nil {
+			return nil, err
+		}
+		if rangeHeader != "" {
+			r.Header.Set("Range", rangeHeader)
+		}
+
+		resp, err := httpclient.TransferClient.Do(r)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusForbidden || refreshed || !d.refreshLink(link) {
+			return resp, nil
+		}
+
+		log.Println("download link expired, refreshed")
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,56 @@
+package file
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDownloadState_Add(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []Range
+		add    Range
+		expect []Range
+	}{
+		{"empty", nil, Range{0, 9}, []Range{{0, 9}}},
+		{"adjacent after", []Range{{0, 9}}, Range{10, 19}, []Range{{0, 19}}},
+		{"adjacent before", []Range{{10, 19}}, Range{0, 9}, []Range{{0, 19}}},
+		{"gap after", []Range{{0, 9}}, Range{20, 29}, []Range{{0, 9}, {20, 29}}},
+		{"gap before", []Range{{20, 29}}, Range{0, 9}, []Range{{0, 9}, {20, 29}}},
+		{"fill gap", []Range{{0, 9}, {20, 29}}, Range{10, 19}, []Range{{0, 29}}},
+		{"contained", []Range{{0, 19}}, Range{5, 9}, []Range{{0, 19}}},
+		{"overlap both", []Range{{0, 9}, {20, 29}}, Range{5, 25}, []Range{{0, 29}}},
+		{"cover all", []Range{{5, 9}, {20, 29}}, Range{0, 39}, []Range{{0, 39}}},
+		{"between", []Range{{0, 9}, {40, 49}}, Range{20, 29}, []Range{{0, 9}, {20, 29}, {40, 49}}},
+	}
+
+	for _, tt := range tests {
+		state := &downloadState{Ranges: tt.ranges}
+		state.add(tt.add)
+		if !reflect.DeepEqual(state.Ranges, tt.expect) {
+			t.Errorf("%s: add %v to %v, got %v, expect %v", tt.name, tt.add, tt.ranges, state.Ranges, tt.expect)
+		}
+	}
+}
+
+func TestDownloadState_Contains(t *testing.T) {
+	state := &downloadState{Ranges: []Range{{0, 9}, {20, 29}}}
+	tests := []struct {
+		r      Range
+		expect bool
+	}{
+		{Range{0, 9}, true},
+		{Range{2, 5}, true},
+		{Range{20, 29}, true},
+		{Range{5, 15}, false},
+		{Range{10, 19}, false},
+		{Range{25, 30}, false},
+		{Range{0, 29}, false},
+	}
+
+	for _, tt := range tests {
+		if got := state.contains(tt.r); got != tt.expect {
+			t.Errorf("contains %v, got %v, expect %v", tt.r, got, tt.expect)
+		}
+	}
+}
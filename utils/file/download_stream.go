@@ -0,0 +1,186 @@
+package file
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/jsyzchen/pan/utils"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+)
+
+// 流式下载时每个分片的大小，内存中最多缓存PartCoroutineNum个分片
+const streamPartSize = 4194304 // 4M
+
+// 分片并发下载，按顺序写入w，返回写入的字节数，不会写入FilePath
+// 适合将网盘文件直接写入http响应或者解压等场景，Md5不为空时写入完成后校验，不一致时返回*ChecksumError
+// 分片重试后仍然失败时返回*MultiError，此时w中已经写入了失败分片之前的内容
+func (d *Downloader) DownloadTo(ctx context.Context, w io.Writer) (int64, error) {
+	isSupportRange, err := d.head(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var fileHash hash.Hash
+	var sink io.Writer = ioutil.Discard
+	if d.Md5 != "" {
+		fileHash = md5.New()
+		sink = fileHash
+	}
+	if d.FileSize == 0 {
+		return 0, d.checkStreamMd5(fileHash)
+	}
+
+	partSize := streamPartSize
+	if d.PartSize > 0 && d.PartSize < partSize {
+		partSize = d.PartSize
+	}
+	jobs := splitStreamParts(d.FileSize, partSize)
+	if !isSupportRange {
+		jobs = jobs[:1]
+		jobs[0].To = d.FileSize - 1
+	}
+	partSizes := make([]int64, len(jobs))
+	for i, job := range jobs {
+		partSizes[i] = int64(job.To - job.From + 1)
+	}
+	d.tracker = NewProgressTracker(d.OnProgress, partSizes)
+
+	if len(jobs) == 1 {//不支持Range下载或者文件比较小，不缓存直接写入w
+		out := &streamWriterAt{w: w}
+		d.tracker.SetPartState(0, PartRunning)
+		if partErr := d.downloadPartWithRetry(ctx, out, jobs[0], sink); partErr != nil {
+			d.tracker.SetPartState(0, PartFailed)
+			return out.offset, &MultiError{Errors: []*PartError{partErr}}
+		}
+		d.tracker.SetPartState(0, PartDone)
+		return out.offset, d.checkStreamMd5(fileHash)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	partCoroutineNum := d.PartCoroutineNum
+	if partCoroutineNum <= 0 {
+		partCoroutineNum = 1
+	}
+	// 按分片顺序排队，队列满时暂停下载新的分片，加上正在写入w的分片最多同时有partCoroutineNum个
+	queue := make(chan *streamPart, partCoroutineNum-1)
+	go func() {
+		defer close(queue)
+		for _, job := range jobs {
+			part := &streamPart{Part: job, done: make(chan *PartError, 1)}
+			select {
+			case queue <- part:
+			case <-ctx.Done():
+				return
+			}
+			go d.downloadStreamPart(ctx, part)
+		}
+	}()
+
+	var written int64
+	for part := range queue {
+		partErr := <-part.done
+		if ctx.Err() != nil {
+			return written, ctx.Err()
+		}
+		if partErr != nil {
+			log.Println("下载文件失败:", partErr)
+			return written, &MultiError{Errors: []*PartError{partErr}}
+		}
+		sink.Write(part.data)
+		n, err := w.Write(part.data)
+		written += int64(n)
+		part.data = nil
+		if err != nil {
+			return written, err
+		}
+	}
+
+	if written < int64(d.FileSize) {//队列因ctx取消提前关闭
+		return written, ctx.Err()
+	}
+
+	return written, d.checkStreamMd5(fileHash)
+}
+
+// 等待按顺序写入的分片
+type streamPart struct {
+	Part
+	data []byte
+	done chan *PartError
+}
+
+// 下载分片到内存，结果通过part.done返回
+func (d *Downloader) downloadStreamPart(ctx context.Context, part *streamPart) {
+	d.tracker.SetPartState(part.Index, PartRunning)
+	data := make([]byte, part.To-part.From+1)
+	partErr := d.downloadPartWithRetry(ctx, &bytesWriterAt{buf: data, base: int64(part.From)}, part.Part, ioutil.Discard)
+	if partErr != nil {
+		d.tracker.SetPartState(part.Index, PartFailed)
+	} else {
+		part.data = data
+		d.tracker.SetPartState(part.Index, PartDone)
+	}
+	part.done <- partErr
+}
+
+// fileHash为空时不校验
+func (d *Downloader) checkStreamMd5(fileHash hash.Hash) error {
+	if fileHash == nil {
+		return nil
+	}
+	expected := utils.DecryptMd5(d.Md5)
+	actual := hex.EncodeToString(fileHash.Sum(nil))
+	if actual != expected {
+		return &ChecksumError{Path: d.FilePath, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// 按partSize切分文件，最后一个分片可能比partSize小
+func splitStreamParts(fileSize, partSize int) []Part {
+	var jobs []Part
+	for from := 0; from < fileSize; from += partSize {
+		to := from + partSize - 1
+		if to >= fileSize {
+			to = fileSize - 1
+		}
+		jobs = append(jobs, Part{Index: len(jobs), From: from, To: to})
+	}
+	return jobs
+}
+
+// 只能按顺序写入的WriterAt，用于不缓存直接写入io.Writer
+type streamWriterAt struct {
+	w      io.Writer
+	offset int64
+}
+
+func (sw *streamWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if off != sw.offset {
+		return 0, errors.New(fmt.Sprintf("non-sequential write, offset:%d expected:%d", off, sw.offset))
+	}
+	n, err := sw.w.Write(p)
+	sw.offset += int64(n)
+	return n, err
+}
+
+// 写入内存中的WriterAt，base为buf[0]在文件中的位置
+type bytesWriterAt struct {
+	buf  []byte
+	base int64
+}
+
+func (bw *bytesWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	start := off - bw.base
+	if start < 0 || start+int64(len(p)) > int64(len(bw.buf)) {
+		return 0, errors.New(fmt.Sprintf("write out of range, offset:%d len:%d", off, len(p)))
+	}
+	return copy(bw.buf[start:], p), nil
+}
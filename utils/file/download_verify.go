@@ -0,0 +1,185 @@
+package file
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/jsyzchen/pan/utils"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// 下载的文件md5与网盘不一致，重新下载后仍然不一致时返回，可以通过errors.As判断
+type ChecksumError struct {
+	Path     string
+	Expected string
+	Actual   string
+	Parts    []int //重新下载过的分片序号
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch, path[%s] expected[%s] actual[%s]", e.Path, e.Expected, e.Actual)
+}
+
+// 下载时按文件顺序计算md5，写入位置正好在已计算部分之后的数据直接计算，不需要再读取
+// 并发的分片写入位置在前面之后时先只记录写入的长度，前面的内容都计算完后再从文件中读取这部分，刚写入的数据通常还在系统缓存中
+type orderedHasher struct {
+	mu      sync.Mutex
+	r       io.ReaderAt
+	jobs    []Part
+	written []int64 //每个分片从From开始已连续写入的字节数
+	next    int64 //文件中next之前的内容都已计算
+	cur     int //next所在的分片
+	hash    hash.Hash
+	err     error
+}
+
+func newOrderedHasher(r io.ReaderAt, jobs []Part) *orderedHasher {
+	return &orderedHasher{
+		r: r,
+		jobs: jobs,
+		written: make([]int64, len(jobs)),
+		hash: md5.New(),
+	}
+}
+
+// 包装out，写入分片index的内容同时计算md5，h为nil时直接返回out
+func (h *orderedHasher) writerAt(out io.WriterAt, index int) io.WriterAt {
+	if h == nil {
+		return out
+	}
+	return &hashWriterAt{w: out, hasher: h, index: index}
+}
+
+// 分片index在off写入了p
+func (h *orderedHasher) write(index int, off int64, p []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if end := off + int64(len(p)) - int64(h.jobs[index].From); end > h.written[index] {
+		h.written[index] = end
+	}
+	if h.err == nil && off <= h.next && h.next < off+int64(len(p)) {
+		h.hash.Write(p[h.next-off:])
+		h.next = off + int64(len(p))
+	}
+	h.advance()
+}
+
+// 分片index已全部写入，用于断点续传前已下载完成的分片
+func (h *orderedHasher) partDone(index int) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	job := h.jobs[index]
+	h.written[index] = int64(job.To - job.From + 1)
+	h.advance()
+}
+
+// 从文件中读取next之后已经写入但还没有计算的内容，调用前需要持有mu
+func (h *orderedHasher) advance() {
+	for h.err == nil && h.cur < len(h.jobs) {
+		job := h.jobs[h.cur]
+		if end := int64(job.From) + h.written[h.cur]; h.next < end {
+			_, h.err = io.Copy(h.hash, io.NewSectionReader(h.r, h.next, end-h.next))
+			h.next = end
+		}
+		if h.next <= int64(job.To) {//分片还没有写完
+			return
+		}
+		h.cur++
+	}
+}
+
+// 所有分片的md5，有分片未完成时返回错误
+func (h *orderedHasher) sum() (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err != nil {
+		return "", h.err
+	}
+	if h.cur != len(h.jobs) {
+		return "", fmt.Errorf("%d parts not hashed", len(h.jobs)-h.cur)
+	}
+	return hex.EncodeToString(h.hash.Sum(nil)), nil
+}
+
+type hashWriterAt struct {
+	w      io.WriterAt
+	hasher *orderedHasher
+	index  int
+}
+
+func (hw *hashWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	n, err := hw.w.WriteAt(p, off)
+	if n > 0 {
+		hw.hasher.write(hw.index, off, p[:n])
+	}
+	return n, err
+}
+
+// 校验下载的文件，不一致时先重新下载写入有误的分片，仍然不一致时重新下载所有分片
+func (d *Downloader) verify(ctx context.Context, out *os.File, jobs []Part) error {
+	expected := utils.DecryptMd5(d.Md5)
+	actual, err := d.hasher.sum()
+	if err != nil {
+		return err
+	}
+	if actual == expected {
+		return nil
+	}
+	log.Printf("checksum mismatch, expected[%s] actual[%s]", expected, actual)
+
+	// 1. 磁盘上的内容与下载时计算的分片md5不一致，说明写入有误，只重新下载这些分片
+	var refetch []Part
+	for _, job := range jobs {
+		partMd5, err := sectionMd5(out, job)
+		if err != nil {
+			return err
+		}
+		if partMd5 != d.partMd5[job.Index] {
+			refetch = append(refetch, job)
+		}
+	}
+	// 2. 分片都写入正确，说明下载的内容有误，无法确定是哪个分片，重新下载所有分片
+	if len(refetch) == 0 {
+		refetch = jobs
+	}
+
+	log.Printf("refetch %d parts", len(refetch))
+	if err := d.downloadParts(ctx, out, refetch); err != nil {
+		return err
+	}
+
+	if actual, err = readerMd5(io.NewSectionReader(out, 0, int64(d.FileSize))); err != nil {
+		return err
+	}
+	if actual != expected {
+		parts := make([]int, len(refetch))
+		for i, job := range refetch {
+			parts[i] = job.Index
+		}
+		return &ChecksumError{Path: d.FilePath, Expected: expected, Actual: actual, Parts: parts}
+	}
+
+	return nil
+}
+
+// 文件中分片对应内容的md5
+func sectionMd5(r io.ReaderAt, job Part) (string, error) {
+	return readerMd5(io.NewSectionReader(r, int64(job.From), int64(job.To-job.From+1)))
+}
+
+func readerMd5(r io.Reader) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
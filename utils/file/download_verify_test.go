@@ -0,0 +1,113 @@
+package file
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"testing"
+)
+
+// 内存中的文件，记录读取的字节数
+type memFile struct {
+	data []byte
+	read int64
+}
+
+func (m *memFile) WriteAt(p []byte, off int64) (int, error) {
+	return copy(m.data[off:], p), nil
+}
+
+func (m *memFile) ReadAt(p []byte, off int64) (int, error) {
+	n := copy(p, m.data[off:])
+	m.read += int64(n)
+	return n, nil
+}
+
+// 分片index中[from, to)的内容
+type hashWrite struct {
+	index int
+	from  int
+	to    int
+}
+
+func TestOrderedHasher(t *testing.T) {
+	content := make([]byte, 1000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	sum := md5.Sum(content)
+	expectMd5 := hex.EncodeToString(sum[:])
+	jobs := []Part{
+		{Index: 0, From: 0, To: 249},
+		{Index: 1, From: 250, To: 499},
+		{Index: 2, From: 500, To: 749},
+		{Index: 3, From: 750, To: 999},
+	}
+
+	tests := []struct {
+		name       string
+		resumed    []int //断点续传前已下载完成的分片
+		writes     []hashWrite
+		expectRead int64 //需要从文件中读回的字节数
+	}{
+		{
+			name:       "in order",
+			writes:     []hashWrite{{0, 0, 250}, {1, 0, 250}, {2, 0, 250}, {3, 0, 250}},
+			expectRead: 0,
+		},
+		{
+			name:       "reverse",
+			writes:     []hashWrite{{3, 0, 250}, {2, 0, 250}, {1, 0, 250}, {0, 0, 250}},
+			expectRead: 750,
+		},
+		{
+			name:       "interleaved chunks",
+			writes:     []hashWrite{{0, 0, 100}, {1, 0, 100}, {0, 100, 250}, {1, 100, 250}, {3, 0, 250}, {2, 0, 250}},
+			expectRead: 350,
+		},
+		{
+			name:       "resumed parts",
+			resumed:    []int{0, 2},
+			writes:     []hashWrite{{1, 0, 250}, {3, 0, 250}},
+			expectRead: 500,
+		},
+	}
+
+	for _, tt := range tests {
+		out := &memFile{data: make([]byte, len(content))}
+		h := newOrderedHasher(out, jobs)
+		for _, index := range tt.resumed {
+			job := jobs[index]
+			copy(out.data[job.From:job.To+1], content[job.From:job.To+1])
+			h.partDone(index)
+		}
+		for _, w := range tt.writes {
+			job := jobs[w.index]
+			off := job.From + w.from
+			if _, err := h.writerAt(out, w.index).WriteAt(content[off:job.From+w.to], int64(off)); err != nil {
+				t.Fatalf("%s: WriteAt failed, err: %v", tt.name, err)
+			}
+		}
+
+		actual, err := h.sum()
+		if err != nil {
+			t.Errorf("%s: sum failed, err: %v", tt.name, err)
+			continue
+		}
+		if actual != expectMd5 {
+			t.Errorf("%s: md5 %s, expect %s", tt.name, actual, expectMd5)
+		}
+		if out.read != tt.expectRead {
+			t.Errorf("%s: read back %d bytes, expect %d", tt.name, out.read, tt.expectRead)
+		}
+	}
+}
+
+func TestOrderedHasher_Incomplete(t *testing.T) {
+	out := &memFile{data: make([]byte, 20)}
+	h := newOrderedHasher(out, []Part{{Index: 0, From: 0, To: 9}, {Index: 1, From: 10, To: 19}})
+	h.writerAt(out, 1).WriteAt(make([]byte, 10), 10)
+
+	if _, err := h.sum(); err == nil {
+		t.Error("sum succeeded with part 0 not written")
+	}
+}
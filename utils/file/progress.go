@@ -0,0 +1,215 @@
+package file
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// 分片的传输状态
+type PartState int
+
+const (
+	PartPending PartState = iota //等待传输
+	PartRunning //传输中
+	PartDone //传输完成
+	PartFailed //传输失败
+)
+
+// 进度回调的最小间隔，分片状态变化和传输结束时不受限制
+const progressMinInterval = 200 * time.Millisecond
+
+// 传输进度
+type Progress struct {
+	TransferredBytes int64 //已传输的字节数
+	TotalBytes       int64 //总字节数
+	Parts            []PartProgress //每个分片的进度
+	Speed            float64 //瞬时速度，bytes/s，为距离上次回调的平均速度
+	AverageSpeed     float64 //平均速度，bytes/s，不包含断点续传前已传输的部分
+	Elapsed          time.Duration //已用时间
+}
+
+// 分片的传输进度
+type PartProgress struct {
+	Index            int
+	Size             int64
+	TransferredBytes int64
+	State            PartState
+}
+
+// 接收传输进度，同一个传输任务的回调不会并发执行
+type ProgressListener interface {
+	OnProgress(p Progress)
+}
+
+// 函数形式的ProgressListener
+type ProgressFunc func(p Progress)
+
+func (f ProgressFunc) OnProgress(p Progress) {
+	f(p)
+}
+
+// 汇总各个分片协程的传输进度并回调ProgressListener，所有方法都可以并发调用
+type ProgressTracker struct {
+	mu          sync.Mutex
+	listener    ProgressListener
+	total       int64
+	transferred int64
+	startBytes  int64
+	parts       []PartProgress
+	startTime   time.Time
+	lastTime    time.Time
+	lastBytes   int64
+	notifyMu    sync.Mutex
+}
+
+// partSizes为每个分片的大小，大小未知时为0，listener为nil时不回调
+func NewProgressTracker(listener ProgressListener, partSizes []int64) *ProgressTracker {
+	t := &ProgressTracker{
+		listener: listener,
+		parts: make([]PartProgress, len(partSizes)),
+		startTime: time.Now(),
+	}
+	t.lastTime = t.startTime
+	for i, size := range partSizes {
+		t.parts[i] = PartProgress{Index: i, Size: size}
+		t.total += size
+	}
+	return t
+}
+
+// 标记断点续传前已经传输完成的分片，不计入平均速度
+func (t *ProgressTracker) SetPartDone(index int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	part := &t.parts[index]
+	delta := part.Size - part.TransferredBytes
+	part.TransferredBytes = part.Size
+	part.State = PartDone
+	t.transferred += delta
+	t.startBytes += delta
+	t.lastBytes += delta
+	t.mu.Unlock()
+}
+
+// 更新分片状态，重新开始传输的分片已传输字节数清零
+func (t *ProgressTracker) SetPartState(index int, state PartState) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	part := &t.parts[index]
+	if state == PartRunning && part.State != PartRunning {
+		t.transferred -= part.TransferredBytes
+		part.TransferredBytes = 0
+	}
+	part.State = state
+	t.mu.Unlock()
+	t.notify(true)
+}
+
+// 分片传输失败后重新传输，已传输的字节数回退
+func (t *ProgressTracker) ResetPart(index int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	part := &t.parts[index]
+	t.transferred -= part.TransferredBytes
+	part.TransferredBytes = 0
+	t.mu.Unlock()
+	t.notify(true)
+}
+
+// 分片新传输了n个字节
+func (t *ProgressTracker) Add(index int, n int64) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	part := &t.parts[index]
+	if part.Size > 0 && part.TransferredBytes+n > part.Size {//重试时服务端可能返回多余的数据
+		n = part.Size - part.TransferredBytes
+	}
+	part.TransferredBytes += n
+	t.transferred += n
+	t.mu.Unlock()
+	t.notify(false)
+}
+
+// 包装r，读取的字节数计入分片index
+func (t *ProgressTracker) Reader(index int, r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &progressReader{r: r, tracker: t, index: index}
+}
+
+// 当前进度，t为nil时返回空的Progress
+func (t *ProgressTracker) Progress() Progress {
+	if t == nil {
+		return Progress{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshot(time.Now())
+}
+
+func (t *ProgressTracker) snapshot(now time.Time) Progress {
+	p := Progress{
+		TransferredBytes: t.transferred,
+		TotalBytes: t.total,
+		Parts: make([]PartProgress, len(t.parts)),
+		Elapsed: now.Sub(t.startTime),
+	}
+	copy(p.Parts, t.parts)
+	if d := now.Sub(t.lastTime).Seconds(); d > 0 {
+		p.Speed = float64(t.transferred-t.lastBytes) / d
+	}
+	if d := p.Elapsed.Seconds(); d > 0 {
+		p.AverageSpeed = float64(t.transferred-t.startBytes) / d
+	}
+	return p
+}
+
+// 回调listener，force为false时限制回调频率
+func (t *ProgressTracker) notify(force bool) {
+	if t.listener == nil {
+		return
+	}
+
+	if !force && !t.due() {
+		return
+	}
+
+	// 串行回调，listener不需要处理并发，并且按时间顺序收到进度
+	t.notifyMu.Lock()
+	defer t.notifyMu.Unlock()
+	t.mu.Lock()
+	now := time.Now()
+	p := t.snapshot(now)
+	t.lastTime, t.lastBytes = now, t.transferred
+	t.mu.Unlock()
+	t.listener.OnProgress(p)
+}
+
+// 距离上次回调是否已超过最小间隔
+func (t *ProgressTracker) due() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return (t.total > 0 && t.transferred >= t.total) || time.Since(t.lastTime) >= progressMinInterval
+}
+
+type progressReader struct {
+	r       io.Reader
+	tracker *ProgressTracker
+	index   int
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.tracker.Add(pr.index, int64(n))
+	return n, err
+}
@@ -0,0 +1,161 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// 顺序读取时默认每次请求的最小字节数，减少Range请求次数
+const defaultReadAhead = 1048576 // 1M
+
+// 通过下载地址的Range请求随机读取网盘文件，实现了io.ReaderAt和io.ReadSeeker
+// ReadAt可以并发调用，Read和Seek不能并发调用
+type RemoteFile struct {
+	ReadAhead   int64 //每次请求的最小字节数，多读取的部分缓存起来供后续读取使用，默认1M，小于0时不预读
+	downloader  *Downloader
+	size        int64
+	offset      int64
+	mu          sync.Mutex
+	cache       []byte //预读的数据
+	cacheOffset int64  //预读数据在文件中的起始位置
+}
+
+var (
+	_ io.ReaderAt   = (*RemoteFile)(nil)
+	_ io.ReadSeeker = (*RemoteFile)(nil)
+)
+
+// 读取downloader的下载地址对应的文件，downloader.FileSize为0时通过HEAD请求获取文件大小
+// 请求失败时的重试、下载地址刷新以及限速与downloader的设置相同
+func NewRemoteFile(downloader *Downloader) (*RemoteFile, error) {
+	return NewRemoteFileWithContext(context.Background(), downloader)
+}
+
+// 同NewRemoteFile，ctx用于获取文件大小的请求
+func NewRemoteFileWithContext(ctx context.Context, downloader *Downloader) (*RemoteFile, error) {
+	if downloader.FileSize == 0 {
+		if _, err := downloader.head(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RemoteFile{
+		ReadAhead: defaultReadAhead,
+		downloader: downloader,
+		size: int64(downloader.FileSize),
+	}, nil
+}
+
+// 文件大小
+func (f *RemoteFile) Size() int64 {
+	return f.size
+}
+
+func (f *RemoteFile) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (f *RemoteFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("RemoteFile.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("RemoteFile.Seek: negative position")
+	}
+	f.offset = offset
+
+	return offset, nil
+}
+
+// 读取的范围在预读缓存中时不发送请求，超过文件末尾时返回io.EOF
+func (f *RemoteFile) ReadAt(p []byte, off int64) (int, error) {
+	return f.ReadAtWithContext(context.Background(), p, off)
+}
+
+// 同ReadAt，ctx取消时中断请求
+func (f *RemoteFile) ReadAtWithContext(ctx context.Context, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("RemoteFile.ReadAt: negative offset")
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+
+	total := 0
+	for total < len(p) && off < f.size {
+		if n := f.readCache(p[total:], off); n > 0 {
+			total += n
+			off += int64(n)
+			continue
+		}
+
+		want := int64(len(p) - total)
+		if off+want > f.size {
+			want = f.size - off
+		}
+		if want >= f.ReadAhead {//读取的数据比预读多，直接读取到p中
+			if err := f.readRange(ctx, p[total:int64(total)+want], off); err != nil {
+				return total, err
+			}
+			total += int(want)
+			off += want
+			continue
+		}
+
+		size := f.ReadAhead
+		if off+size > f.size {
+			size = f.size - off
+		}
+		buf := make([]byte, size)
+		if err := f.readRange(ctx, buf, off); err != nil {
+			return total, err
+		}
+		f.mu.Lock()
+		f.cache, f.cacheOffset = buf, off
+		f.mu.Unlock()
+	}
+
+	if total < len(p) {
+		return total, io.EOF
+	}
+
+	return total, nil
+}
+
+// 从预读缓存中读取off开始的数据，返回读取的字节数
+func (f *RemoteFile) readCache(p []byte, off int64) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if off < f.cacheOffset || off >= f.cacheOffset+int64(len(f.cache)) {
+		return 0
+	}
+	return copy(p, f.cache[off-f.cacheOffset:])
+}
+
+// 读取文件中off开始的len(p)个字节，失败时按downloader的设置重试
+func (f *RemoteFile) readRange(ctx context.Context, p []byte, off int64) error {
+	part := Part{Index: -1, From: int(off), To: int(off) + len(p) - 1}
+	if partErr := f.downloader.downloadPartWithRetry(ctx, &bytesWriterAt{buf: p, base: off}, part, ioutil.Discard); partErr != nil {
+		return partErr.Err
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package file
 
 import (
 	"bytes"
+	"context"
 	"github.com/jsyzchen/pan/utils/httpclient"
+	"github.com/jsyzchen/pan/utils/ratelimit"
 	"io"
 	"io/ioutil"
 	"log"
@@ -15,6 +17,9 @@ import (
 type Uploader struct {
 	Url string
 	FilePath string
+	Limiter *ratelimit.Limiter //上传限速，为空时不限速
+	Tracker *ProgressTracker //不为空时UploadByByte发送的文件内容实时计入分片PartIndex的进度
+	PartIndex int
 }
 
 //NewFileUploader
@@ -27,6 +32,11 @@ func NewFileUploader(url, filePath string) *Uploader {
 
 // 上传文件
 func (u *Uploader) Upload() ([]byte, error) {
+	return u.UploadWithContext(context.Background())
+}
+
+// 上传文件，ctx取消时中断请求
+func (u *Uploader) UploadWithContext(ctx context.Context) ([]byte, error) {
 	ret := []byte("")
 
 	bodyBuf := &bytes.Buffer{}
@@ -55,10 +65,11 @@ func (u *Uploader) Upload() ([]byte, error) {
 	bodyWriter.Close()
 
 	//提交请求
-	request, err := http.NewRequest("POST", u.Url, bodyBuf)
+	request, err := http.NewRequestWithContext(ctx, "POST", u.Url, u.Limiter.Reader(ctx, bodyBuf))
 	if err != nil {
 		return ret, err
 	}
+	request.ContentLength = int64(bodyBuf.Len())
 
 	request.Header.Add("Content-Type", contentType)
 	//随机设置一个User-Agent
@@ -66,8 +77,7 @@ func (u *Uploader) Upload() ([]byte, error) {
 	request.Header.Set("User-Agent", userAgent)
 
 	//处理返回结果
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	resp, err := httpclient.TransferClient.Do(request)
 	//打印接口返回信息
 	if err != nil {
 		log.Println("request uploadUrl failed, err:", err)
@@ -85,31 +95,36 @@ func (u *Uploader) Upload() ([]byte, error) {
 
 //直接通过字节上传
 func (u *Uploader) UploadByByte(fileByte []byte) ([]byte, error) {
-	ret := []byte("")
+	return u.UploadByByteWithContext(context.Background(), fileByte)
+}
 
-	bodyBuf := &bytes.Buffer{}
+//直接通过字节上传，ctx取消时中断请求
+func (u *Uploader) UploadByByteWithContext(ctx context.Context, fileByte []byte) ([]byte, error) {
+	ret := []byte("")
 
-	bodyWriter := multipart.NewWriter(bodyBuf)
+	// 文件内容不复制到缓冲区，只缓存multipart的头部和结尾，发送时按顺序拼接
+	headBuf := &bytes.Buffer{}
+	bodyWriter := multipart.NewWriter(headBuf)
 	//"file" 为接收时定义的参数名
-	fileWriter, err := bodyWriter.CreateFormFile("file", filepath.Base(u.FilePath))
+	_, err := bodyWriter.CreateFormFile("file", filepath.Base(u.FilePath))
 	if err != nil {
 		log.Println("error writing to buffer, err:", err)
 		return ret, err
 	}
-
-	//iocopy
-	_, err = io.Copy(fileWriter, bytes.NewReader(fileByte))
-	if err != nil {
-		return ret, err
-	}
+	head := append([]byte(nil), headBuf.Bytes()...)
+	headBuf.Reset()
 	contentType := bodyWriter.FormDataContentType()
 	bodyWriter.Close()
+	tail := headBuf.Bytes()
+
+	body := io.MultiReader(bytes.NewReader(head), u.Tracker.Reader(u.PartIndex, bytes.NewReader(fileByte)), bytes.NewReader(tail))
 
 	//提交请求
-	request, err := http.NewRequest("POST", u.Url, bodyBuf)
+	request, err := http.NewRequestWithContext(ctx, "POST", u.Url, u.Limiter.Reader(ctx, body))
 	if err != nil {
 		return ret, err
 	}
+	request.ContentLength = int64(len(head) + len(fileByte) + len(tail))
 
 	request.Header.Add("Content-Type", contentType)
 	//随机设置一个User-Agent
@@ -117,8 +132,7 @@ func (u *Uploader) UploadByByte(fileByte []byte) ([]byte, error) {
 	request.Header.Set("User-Agent", userAgent)
 
 	//处理返回结果
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	resp, err := httpclient.TransferClient.Do(request)
 	//打印接口返回信息
 	if err != nil {
 		log.Println("上传错误信息：", err)
@@ -1,9 +1,12 @@
 package utils
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"net/url"
 	"strconv"
+	"strings"
+	"unicode"
 )
 
 func InterfaceToString(val interface{}) string {
@@ -48,3 +51,27 @@ func StructToUrlQuery(m interface{}) (string, error) {
 	query = v.Encode()
 	return query, nil
 }
+
+// 网盘文件列表、文件信息返回的md5有可能是经过加密的，需要解密后才是文件真实的md5，未加密的md5原样返回
+func DecryptMd5(encryptMd5 string) string {
+	if len(encryptMd5) != 32 {
+		return encryptMd5
+	}
+	if _, err := hex.DecodeString(encryptMd5); err == nil {
+		return encryptMd5
+	}
+
+	var out strings.Builder
+	out.Grow(len(encryptMd5))
+	for i, n := 0, int64(0); i < len(encryptMd5); i++ {
+		if i == 9 {
+			n = int64(unicode.ToLower(rune(encryptMd5[i])) - 'g')
+		} else {
+			n, _ = strconv.ParseInt(encryptMd5[i:i+1], 16, 64)
+		}
+		out.WriteString(strconv.FormatInt(n^int64(15&i), 16))
+	}
+
+	decryptMd5 := out.String()
+	return decryptMd5[8:16] + decryptMd5[:8] + decryptMd5[24:32] + decryptMd5[16:24]
+}
@@ -1,6 +1,7 @@
 package httpclient
 
 import (
+	"context"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
@@ -14,18 +15,46 @@ type HttpResponse struct {
 	Body       []byte
 }
 
+// 接口请求的默认超时时间
+const DefaultTimeout = 60 * time.Second
+
+// 上传下载时等待响应头的超时时间，数据量较大不设置整体超时
+const ResponseHeaderTimeout = 60 * time.Second
+
+// 接口请求使用的client，可以替换以修改超时时间或代理
+var DefaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// 上传下载使用的client，只限制建立连接和等待响应头的时间，避免连接挂起时一直阻塞
+var TransferClient = &http.Client{Transport: newTransferTransport()}
+
+func newTransferTransport() http.RoundTripper {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return http.DefaultTransport
+	}
+	transport = transport.Clone()
+	transport.ResponseHeaderTimeout = ResponseHeaderTimeout
+	return transport
+}
+
 func SendRequest(method string, url string, header map[string]string, body string) (HttpResponse, error) {
-	client := &http.Client{}
+	return SendRequestWithContext(context.Background(), method, url, header, body)
+}
+
+// 发送请求，ctx取消时中断请求，超时时间为DefaultClient的设置
+func SendRequestWithContext(ctx context.Context, method string, url string, header map[string]string, body string) (HttpResponse, error) {
 	var res HttpResponse
 	var request *http.Request
 	var err error
 	if method == "POST" {
-		request, err = http.NewRequest(method, url, strings.NewReader(body))
-		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request, err = http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+		if err == nil {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
 	} else if method == "PUT" {
-		request, err = http.NewRequest(method, url, strings.NewReader(body))
+		request, err = http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
 	} else {
-		request, err = http.NewRequest(method, url, nil)
+		request, err = http.NewRequestWithContext(ctx, method, url, nil)
 	}
 
 	if err != nil {
@@ -39,7 +68,7 @@ func SendRequest(method string, url string, header map[string]string, body strin
 			request.Header.Set(k, v)
 		}
 	}
-	response, err := client.Do(request)
+	response, err := DefaultClient.Do(request)
 	if response != nil {
 		res.StatusCode = response.StatusCode
 		res.Header = response.Header
@@ -60,22 +89,42 @@ func Post(url string, header map[string]string, body string) (HttpResponse, erro
 	return SendRequest("POST", url, header, body)
 }
 
+func PostWithContext(ctx context.Context, url string, header map[string]string, body string) (HttpResponse, error) {
+	return SendRequestWithContext(ctx, "POST", url, header, body)
+}
+
 func Put(url string, header map[string]string, body string) (HttpResponse, error) {
 	return SendRequest("PUT", url, header, body)
 }
 
+func PutWithContext(ctx context.Context, url string, header map[string]string, body string) (HttpResponse, error) {
+	return SendRequestWithContext(ctx, "PUT", url, header, body)
+}
+
 func Get(url string, header map[string]string) (HttpResponse, error) {
 	return SendRequest("GET", url, header, "")
 }
 
+func GetWithContext(ctx context.Context, url string, header map[string]string) (HttpResponse, error) {
+	return SendRequestWithContext(ctx, "GET", url, header, "")
+}
+
 func Head(url string, header map[string]string) (HttpResponse, error) {
 	return SendRequest("HEAD", url, header, "")
 }
 
+func HeadWithContext(ctx context.Context, url string, header map[string]string) (HttpResponse, error) {
+	return SendRequestWithContext(ctx, "HEAD", url, header, "")
+}
+
 func Delete(url string, header map[string]string) (HttpResponse, error) {
 	return SendRequest("DELETE", url, header,"")
 }
 
+func DeleteWithContext(ctx context.Context, url string, header map[string]string) (HttpResponse, error) {
+	return SendRequestWithContext(ctx, "DELETE", url, header,"")
+}
+
 // 随机获取User-Agent
 func GetRandomUserAgent() string {
 	userAgentList := []string{
@@ -0,0 +1,142 @@
+// 令牌桶限速，同一个Limiter可以在多个上传、下载任务之间共享，限制的是所有任务的总速度
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// 等待令牌时的最长单次等待时间，保证修改限速后能尽快生效
+const maxWaitInterval = 100 * time.Millisecond
+
+// 令牌桶，桶容量为1秒的令牌数，nil或限速小于等于0时不限速
+type Limiter struct {
+	mu     sync.Mutex
+	limit  int64 //每秒字节数
+	tokens float64
+	last   time.Time
+}
+
+// bytesPerSec为每秒最多传输的字节数，小于等于0时不限速
+func NewLimiter(bytesPerSec int64) *Limiter {
+	return &Limiter{
+		limit: bytesPerSec,
+		tokens: float64(bytesPerSec),
+		last: time.Now(),
+	}
+}
+
+// 修改限速，正在进行的传输也会立即生效，l为nil时不做任何操作
+func (l *Limiter) SetLimit(bytesPerSec int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.limit = bytesPerSec
+	if l.tokens > float64(bytesPerSec) {
+		l.tokens = float64(bytesPerSec)
+	}
+}
+
+// 当前限速，每秒字节数
+func (l *Limiter) Limit() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// 等待直到可以传输n个字节，ctx取消时返回ctx.Err()
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	for n > 0 {
+		wait, taken := l.take(n)
+		n -= taken
+		if wait <= 0 {
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return ctx.Err()
+}
+
+// 取出最多n个令牌，返回取出的数量，令牌不足时返回需要等待的时间
+func (l *Limiter) take(n int) (time.Duration, int) {
+	if l == nil {
+		return 0, n
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit <= 0 {
+		return 0, n
+	}
+
+	l.refill(time.Now())
+	if l.tokens < 1 {
+		wait := time.Duration((1 - l.tokens) / float64(l.limit) * float64(time.Second))
+		if wait > maxWaitInterval {
+			wait = maxWaitInterval
+		}
+		if wait <= 0 {
+			wait = time.Millisecond
+		}
+		return wait, 0
+	}
+
+	taken := n
+	if float64(taken) > l.tokens {
+		taken = int(l.tokens)
+	}
+	l.tokens -= float64(taken)
+	return 0, taken
+}
+
+func (l *Limiter) refill(now time.Time) {
+	if l.limit > 0 {
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.limit)
+		if l.tokens > float64(l.limit) {
+			l.tokens = float64(l.limit)
+		}
+	}
+	l.last = now
+}
+
+// 包装r，读取速度受l限制
+func (l *Limiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, limiter: l}
+}
+
+type reader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if limit := r.limiter.Limit(); limit > 0 && int64(len(p)) > limit {//单次读取不超过桶容量，避免突发流量
+		p = p[:limit]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Refill(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		tokens  float64
+		elapsed time.Duration
+		expect  float64
+	}{
+		{"half second", 1000, 0, 500 * time.Millisecond, 500},
+		{"capped at limit", 1000, 0, 2 * time.Second, 1000},
+		{"add to remaining", 1000, 300, 100 * time.Millisecond, 400},
+		{"unlimited", 0, 0, time.Second, 0},
+	}
+
+	for _, tt := range tests {
+		start := time.Now()
+		l := &Limiter{limit: tt.limit, tokens: tt.tokens, last: start}
+		l.refill(start.Add(tt.elapsed))
+		if l.tokens != tt.expect {
+			t.Errorf("%s: tokens %v, expect %v", tt.name, l.tokens, tt.expect)
+		}
+		if !l.last.Equal(start.Add(tt.elapsed)) {
+			t.Errorf("%s: last not updated", tt.name)
+		}
+	}
+}
+
+func TestLimiter_Take(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int64
+		tokens      float64
+		n           int
+		expectTaken int
+		expectWait  bool
+	}{
+		{"enough tokens", 1000, 1000, 300, 300, false},
+		{"partial", 1000, 200, 300, 200, false},
+		{"empty bucket", 1000, 0, 300, 0, true},
+		{"unlimited", 0, 0, 300, 300, false},
+		{"negative limit", -1, 0, 300, 300, false},
+	}
+
+	for _, tt := range tests {
+		l := &Limiter{limit: tt.limit, tokens: tt.tokens, last: time.Now()}
+		wait, taken := l.take(tt.n)
+		if taken != tt.expectTaken {
+			t.Errorf("%s: taken %d, expect %d", tt.name, taken, tt.expectTaken)
+		}
+		if (wait > 0) != tt.expectWait {
+			t.Errorf("%s: wait %v, expect wait %v", tt.name, wait, tt.expectWait)
+		}
+		if wait > maxWaitInterval {
+			t.Errorf("%s: wait %v exceeds %v", tt.name, wait, maxWaitInterval)
+		}
+	}
+}
+
+func TestLimiter_SetLimit(t *testing.T) {
+	l := NewLimiter(1000)
+	l.SetLimit(100)
+	if l.Limit() != 100 {
+		t.Errorf("Limit %d, expect 100", l.Limit())
+	}
+	if l.tokens > 100 {
+		t.Errorf("tokens %v exceed the new limit 100", l.tokens)
+	}
+
+	var nilLimiter *Limiter
+	nilLimiter.SetLimit(100)
+	if nilLimiter.Limit() != 0 {
+		t.Errorf("nil Limit %d, expect 0", nilLimiter.Limit())
+	}
+	if err := nilLimiter.WaitN(context.Background(), 1<<20); err != nil {
+		t.Errorf("nil WaitN failed, err: %v", err)
+	}
+	r := bytes.NewReader(nil)
+	if nilLimiter.Reader(context.Background(), r) != r {
+		t.Error("nil Reader should return r")
+	}
+}
+
+func TestLimiter_Reader(t *testing.T) {
+	l := NewLimiter(10000)
+	data := make([]byte, 15000)
+
+	start := time.Now()
+	got, err := ioutil.ReadAll(l.Reader(context.Background(), bytes.NewReader(data)))
+	elapsed := time.Since(start)
+
+	if err != nil || len(got) != len(data) {
+		t.Fatalf("read %d bytes, err: %v", len(got), err)
+	}
+	// 桶中初始有10000个令牌，剩余5000字节需要等待约0.5秒
+	if elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("read 15000 bytes at 10000 B/s took %v, expect about 500ms", elapsed)
+	}
+}
+
+func TestLimiter_WaitNCanceled(t *testing.T) {
+	l := NewLimiter(10)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := l.WaitN(ctx, 1000); err != context.DeadlineExceeded {
+		t.Errorf("got %v, expect context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// 重试间隔的初始值和上限
+const (
+	RetryBaseInterval = time.Second
+	RetryMaxInterval  = 30 * time.Second
+)
+
+// 第attempt次重试前的等待时间，从RetryBaseInterval开始指数退避，不超过RetryMaxInterval，并加上随机抖动
+func RetryInterval(attempt int) time.Duration {
+	interval := RetryBaseInterval << uint(attempt)
+	if interval <= 0 || interval > RetryMaxInterval {
+		interval = RetryMaxInterval
+	}
+	return interval/2 + time.Duration(rand.Int63n(int64(interval/2)+1))
+}
+
+// 等待d，ctx取消时提前返回ctx.Err()
+func SleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}